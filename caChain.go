@@ -0,0 +1,135 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file obtains the CA chain of a newly issued certificate from EJBCA
+ *  (ca_chain_source = ejbca) and keeps a local copy of it with the fetch date.
+ * 
+ * */
+
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
+)
+
+
+// first line of a cached chain file, followed by the fetch date
+const chainCacheHeader = "# CA chain fetched from EJBCA at "
+
+
+/**
+ *  updateCAChain fetches the CA chain of the issued certificate from EJBCA, orders it
+ *  issuer first / root last and makes it the job's CA chain (ca_chain, delivery and verify).
+ *  The chain is cached; if fetching fails the cached chain is used instead.
+ *  Params:
+ *    - job: job the certificate was issued for.
+ *    - hc: mTLS-configured HTTP client.
+ *    - issued: certificate just received from the CA.
+ *  Returns:
+ *    - error: non-nil if neither a fetched nor a cached chain is available.
+ * */
+func updateCAChain(job *config.Job, hc *http.Client, issued *x509.Certificate) error {
+
+	log := job.Log()
+
+	chainPEM, err := fetchCAChain(job, hc, issued)
+	if err != nil {
+		log.Warnf("fetch CA chain from EJBCA: %v - trying cached chain %s\n", err, job.Ca.CAChainCache)
+
+		cached, fetched, cerr := readChainCache(job.Ca.CAChainCache)
+		if cerr != nil {
+			return fmt.Errorf("fetch CA chain: %w (no cached chain: %v)", err, cerr)
+		}
+		log.Warnf("using cached CA chain fetched at %s\n", fetched.Format(time.RFC3339))
+		chainPEM = cached
+	} else if err := writeChainCache(job.Ca.CAChainCache, chainPEM); err != nil {
+		log.Warnf("cache CA chain: %v\n", err)
+	}
+
+	job.Ca.CAChain = chainPEM
+	job.Ca.CACertLoaded = chainPEM
+	return nil
+}
+
+
+/**
+ *  fetchCAChain requests the chain of the last certificate of the End Entity and
+ *  returns the CA certificates of the issued certificate in PEM form.
+ * */
+func fetchCAChain(job *config.Job, hc *http.Client, issued *x509.Certificate) (string, error) {
+
+	certs, err := ejbcaHttpsClient.GetLastCertChainViaGowsdl(ejbcaHttpsClient.GetContext(job.Name), job, hc)
+	if err != nil {
+		return "", err
+	}
+	chain, err := ejbcaHttpsClient.OrderCAChain(issued, certs)
+	if err != nil {
+		return "", err
+	}
+	b, err := ejbcaHttpsClient.ChainToPEM(chain)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+
+/**
+ *  writeChainCache stores a chain with the current date as header line.
+ *  The file is replaced atomically.
+ * */
+func writeChainCache(path, chainPEM string) error {
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	data := chainCacheHeader + time.Now().UTC().Format(time.RFC3339) + "\n" + chainPEM
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+
+/**
+ *  readChainCache reads a chain written by writeChainCache.
+ *  Returns:
+ *    - string: cached chain in PEM form.
+ *    - time.Time: date the chain was fetched (zero if the header is missing).
+ *    - error: non-nil if the file cannot be read or contains no certificate.
+ * */
+func readChainCache(path string) (string, time.Time, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	s := string(data)
+
+	var fetched time.Time
+	if strings.HasPrefix(s, chainCacheHeader) {
+		line, rest, _ := strings.Cut(s, "\n")
+		fetched, _ = time.Parse(time.RFC3339, strings.TrimPrefix(line, chainCacheHeader))
+		s = rest
+	}
+	if !strings.Contains(s, "-----BEGIN CERTIFICATE-----") {
+		return "", time.Time{}, fmt.Errorf("%s: no certificate found", path)
+	}
+	return s, fetched, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  This file implements the "check-config" command which validates all jobs
+ *  strictly and reports every problem with file and line. The same check is
+ *  made before a job is run.
+ *
+ * */
+
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ssh"
+)
+
+
+/**
+ *  runCheckConfig validates the configuration and all enabled jobs and prints every problem.
+ *  Exits with exitOK if no problem was found, otherwise with exitConfigError.
+ * */
+func runCheckConfig() {
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check-config: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if cfg.Jobs, err = selectJobs(cfg.Jobs); err != nil {
+		fmt.Fprintf(os.Stderr, "check-config: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	problems := cfg.Problems
+	for i := range cfg.Jobs {
+		problems = append(problems, checkJob(&cfg.Jobs[i])...)
+	}
+
+	for _, p := range problems {
+		fmt.Println(p.String())
+	}
+	fmt.Printf("%d job(s) checked, %d problem(s) found\n", len(cfg.Jobs), len(problems))
+
+	if len(problems) > 0 {
+		os.Exit(exitConfigError)
+	}
+	os.Exit(exitOK)
+}
+
+
+/**
+ *  checkJob validates a job strictly: the checks of the config package plus
+ *  the values owned by the main and ssh packages.
+ *  Params:
+ *    - job: job to check.
+ *  Returns:
+ *    - []config.ConfigProblem: all problems found, nil if the job is valid.
+ * */
+func checkJob(job *config.Job) []config.ConfigProblem {
+
+	problems := job.Check()
+
+	problems = append(problems, job.CheckEnum("target", "key_source", job.Target.KeySource, keySourceTarget, keySourceLocal)...)
+	problems = append(problems, job.CheckEnum("target", "delivery", job.Target.Delivery,
+		deliveryScript, ssh.DeliverySFTP, ssh.DeliveryStdin, ssh.DeliveryNative)...)
+	problems = append(problems, job.CheckEnum("target", "deployed_check", job.Target.DeployedCheck,
+		deployedCheckNone, deployedCheckSSH, deployedCheckTLS)...)
+	problems = append(problems, job.CheckEnum("target", "host_key_check", job.Target.HostKeyCheck,
+		ssh.HostKeyInsecure, ssh.HostKeyKnownHosts, ssh.HostKeyFingerprint, ssh.HostKeyTOFU)...)
+
+	switch hostKeyOptions(job).EffectivePolicy() {
+		case ssh.HostKeyKnownHosts:
+			if strings.TrimSpace(job.Target.KnownHosts) == "" {
+				problems = append(problems, job.Problemf("target", "known_hosts", "required by host_key_check = %s", ssh.HostKeyKnownHosts))
+			}
+		case ssh.HostKeyFingerprint:
+			if strings.TrimSpace(job.Target.HostKeyFingerprint) == "" {
+				problems = append(problems, job.Problemf("target", "host_key_fingerprint", "required by host_key_check = %s", ssh.HostKeyFingerprint))
+			}
+	}
+
+	if localKeySource(job) && len(job.Target.SANs.Other) > 0 {
+		problems = append(problems, job.Problemf("target", "subjectAltName", "%s not supported with key_source = %s",
+			strings.Join(job.Target.SANs.Other, ", "), keySourceLocal))
+	}
+	if !localKeySource(job) && strings.TrimSpace(job.Target.CSRCommand) == "" {
+		problems = append(problems, job.Problemf("target", "csr_command", "required key not set (key_source = %s)", keySourceTarget))
+	}
+	if !nativeDelivery(job) && strings.TrimSpace(job.Target.SetCertCommand) == "" {
+		problems = append(problems, job.Problemf("target", "set_cert_command", "required key not set (delivery = %s)", deliveryScript))
+	}
+
+	if _, err := parseFileMode(job.Target.FileMode, defaultFileMode); err != nil {
+		problems = append(problems, job.Problemf("target", "file_mode", "%v", err))
+	}
+	if _, err := parseFileMode(job.Target.KeyFileMode, defaultKeyFileMode); err != nil {
+		problems = append(problems, job.Problemf("target", "key_file_mode", "%v", err))
+	}
+
+	return problems
+}
+
+
+/**
+ *  configErrorResults turns the problems of job files which could not be loaded
+ *  into job results, so they show up in the summary, the report and the exit code.
+ *  Params:
+ *    - problems: load problems of the configuration.
+ *  Returns:
+ *    - []jobResult: one result per job (or file, if the job name is unknown).
+ * */
+func configErrorResults(problems []config.ConfigProblem) []jobResult {
+
+	var results []jobResult
+	index := map[string]int{}
+
+	for _, p := range problems {
+		name := p.Job
+		if name == "" {
+			name = p.File
+		} else if !matchJobName(name) {
+			continue
+		}
+		if i, ok := index[name]; ok {
+			results[i].Err = fmt.Errorf("%w; %s", results[i].Err, p.String())
+			continue
+		}
+		index[name] = len(results)
+		results = append(results, jobResult{
+			Name:   name,
+			Status: statusConfigError,
+			Phase:  phaseConfig,
+			Err:    fmt.Errorf("not loaded: %s", p.String()),
+		})
+	}
+	return results
+}
@@ -0,0 +1,459 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config validates loaded jobs strictly (required keys, files, durations,
+ *  script variables, SANs, ports) and reports every problem with file and line.
+ *
+ */
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+
+/**
+ *  ConfigProblem is one problem found in a job configuration.
+ *
+ */
+type ConfigProblem struct {
+	File    string // file the value comes from (job file or global configuration)
+	Line    int    // line of the key (or of its section if the key is missing), 0 if unknown
+	Job     string // job name, empty if the problem concerns the whole file
+	Section string
+	Key     string
+	Msg     string
+}
+
+
+/**
+ *  String formats the problem as "file:line: job <name>: [section] key: message".
+ *
+ */
+func (p ConfigProblem) String() string {
+	var b strings.Builder
+	b.WriteString(p.File)
+	if p.Line > 0 {
+		b.WriteString(":" + strconv.Itoa(p.Line))
+	}
+	b.WriteString(": ")
+	if p.Job != "" {
+		b.WriteString("job <" + p.Job + ">: ")
+	}
+	if p.Section != "" {
+		b.WriteString("[" + p.Section + "] ")
+	}
+	if p.Key != "" {
+		b.WriteString(p.Key + ": ")
+	}
+	b.WriteString(p.Msg)
+	return b.String()
+}
+
+
+/**
+ *  newProblem creates a problem and looks up the line of the key in the file.
+ *
+ *  Params:
+ *    - file: INI file the value comes from.
+ *    - job: job name, may be empty.
+ *    - section: INI section, may be empty.
+ *    - key: INI key, may be empty.
+ *    - msg: description of the problem.
+ *
+ *  Returns:
+ *    - ConfigProblem: the problem.
+ *
+ */
+func newProblem(file, job, section, key, msg string) ConfigProblem {
+	p := ConfigProblem{File: file, Job: job, Section: section, Key: key, Msg: msg}
+	if section != "" {
+		lines := iniKeyLines(file)
+		if l, ok := lines[strings.ToLower(section+"."+key)]; ok && key != "" {
+			p.Line = l
+		} else {
+			p.Line = lines[strings.ToLower(section)]
+		}
+	}
+	return p
+}
+
+
+/**
+ *  Problemf creates a problem of the job. If the key is not set in the job file but
+ *  taken from the CA profile or the [defaults] of the global configuration, the
+ *  problem points there.
+ *
+ *  Params:
+ *    - section: INI section, e.g. "target".
+ *    - key: INI key, may be empty if the problem concerns the section.
+ *    - format, args: description of the problem.
+ *
+ *  Returns:
+ *    - ConfigProblem: the problem.
+ *
+ */
+func (j *Job) Problemf(section, key, format string, args ...any) ConfigProblem {
+
+	msg := fmt.Sprintf(format, args...)
+	if key == "" || keyInFile(j.File, section, key) {
+		return newProblem(j.File, j.Name, section, key, msg)
+	}
+
+	global := filepath.Join(filepath.Dir(filepath.Dir(j.File)), GlobalConfigFile)
+	globalSection := ""
+	switch {
+		case section == "ca" && j.Ca.Profile != "":
+			globalSection = globalCAProfilePrefix + strings.ToLower(strings.TrimSpace(j.Ca.Profile))
+		case section == "target":
+			globalSection = globalDefaultsSection
+	}
+	if globalSection != "" && keyInFile(global, globalSection, key) {
+		p := newProblem(global, j.Name, globalSection, key, msg)
+		p.Section = section
+		return p
+	}
+	return newProblem(j.File, j.Name, section, key, msg)
+}
+
+
+/**
+ *  keyInFile reports whether a key is set in a section of an INI file.
+ *
+ */
+func keyInFile(file, section, key string) bool {
+	_, ok := iniKeyLines(file)[strings.ToLower(section+"."+key)]
+	return ok
+}
+
+
+/**
+ *  iniKeyLines returns the line numbers of the sections ("section") and keys
+ *  ("section.key", lower case) of an INI file. For repeated keys the last one counts,
+ *  like for the loader.
+ *
+ */
+func iniKeyLines(path string) map[string]int {
+	lines := map[string]int{}
+	f, err := os.Open(path)
+	if err != nil {
+		return lines
+	}
+	defer f.Close()
+
+	section := ""
+	inMultiline := false
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if inMultiline {
+			inMultiline = !strings.HasSuffix(line, `"""`)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			lines[section] = n
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			key, value, ok = strings.Cut(line, ":")
+		}
+		if !ok {
+			continue
+		}
+		lines[section+"."+strings.ToLower(strings.TrimSpace(key))] = n
+		value = strings.TrimSpace(value)
+		inMultiline = strings.HasPrefix(value, `"""`) && (len(value) < 6 || !strings.HasSuffix(value, `"""`))
+	}
+	return lines
+}
+
+
+/**
+ *  logProblems logs problems as errors and returns them.
+ *
+ */
+func logProblems(problems ...ConfigProblem) []ConfigProblem {
+	for _, p := range problems {
+		logger.Errorln(p.String())
+	}
+	return problems
+}
+
+
+// scripts of a job which may reference configuration variables
+var scriptKeys = []string{"csr_command", "set_cert_command", "rollback_command", "reload_command"}
+
+
+/**
+ *  Check validates a loaded job strictly: required keys, existence of the referenced
+ *  files, duration syntax, script variable references, SAN syntax, ports and the
+ *  values of the keys with a fixed set of values owned by this package.
+ *  Checks of values owned by other packages (e.g. delivery) are left to the caller.
+ *
+ *  Returns:
+ *    - []ConfigProblem: all problems found, nil if the job is valid.
+ *
+ */
+func (j *Job) Check() []ConfigProblem {
+
+	var problems []ConfigProblem
+	add := func(section, key, format string, args ...any) {
+		problems = append(problems, j.Problemf(section, key, format, args...))
+	}
+
+	// required keys
+	for _, r := range []struct{ section, key, value string }{
+		{"ca", "host", j.Ca.Host},
+		{"ca", "ejbca_api_url", j.Ca.EJBCAApiUrl},
+		{"ca", "client_cert", j.Ca.ClientCert},
+		{"ca", "client_key", j.Ca.ClientKey},
+		{"ca", "server_cert_chain", j.Ca.ServerCertChain},
+		{"target", "ssh_user", j.Target.SSHUser},
+		{"target", "ssh_key", j.Target.SSHKey},
+	} {
+		if strings.TrimSpace(r.value) == "" {
+			add(r.section, r.key, "required key not set")
+		}
+	}
+
+	// files, optional ones only if set
+	for _, f := range []struct {
+		section, key, path string
+	}{
+		{"ca", "client_cert", j.Ca.ClientCert},
+		{"ca", "client_key", j.Ca.ClientKey},
+		{"ca", "server_cert_chain", j.Ca.ServerCertChain},
+		{"ca", "ca_cert", j.Ca.CACert},
+		{"target", "ssh_key", j.Target.SSHKey},
+		{"target", "known_hosts", j.Target.KnownHosts},
+	} {
+		if strings.TrimSpace(f.path) == "" {
+			continue
+		}
+		if _, err := os.Stat(expandHomeDir(f.path)); err != nil {
+			add(f.section, f.key, "%v", err)
+		}
+	}
+
+	if u, err := url.Parse(j.Ca.EJBCAApiUrl); j.Ca.EJBCAApiUrl != "" && (err != nil || u.Scheme != "https" || u.Host == "") {
+		add("ca", "ejbca_api_url", "%q is no https:// URL", j.Ca.EJBCAApiUrl)
+	}
+
+	// ports
+	if j.Target.SSHPort < 1 || j.Target.SSHPort > 65535 {
+		add("target", "ssh_port", "port %d out of range 1-65535 (not set?)", j.Target.SSHPort)
+	}
+	for _, a := range []struct{ section, key, addr string }{
+		{"target", "probe_address", j.Target.ProbeAddress},
+		{"verify", "tls_address", j.Verify.TLSAddress},
+	} {
+		if a.addr == "" {
+			continue
+		}
+		if err := checkHostPort(a.addr); err != nil {
+			add(a.section, a.key, "%v", err)
+		}
+	}
+
+	// durations
+	if _, err := parseValidity(j.Target.ChangeAfterRaw); err != nil {
+		add("target", "change_after", "invalid duration %q: %v", j.Target.ChangeAfterRaw, err)
+	}
+	if _, err := parseValidity(j.Verify.WaitRaw); err != nil {
+		add("verify", "wait", "invalid duration %q: %v", j.Verify.WaitRaw, err)
+	}
+
+	// SANs and revocation reason, checked at load already but Check stands on its own
+	if _, err := ParseSubjectAltName(j.Target.SubjectAltName); err != nil {
+		add("target", "subjectAltName", "%v", err)
+	}
+	if _, err := ParseRevocationReason(j.Ca.RevocationReasonRaw); err != nil {
+		add("ca", "revocation_reason", "%v", err)
+	}
+
+	// keys with a fixed set of values
+	for _, e := range []struct {
+		section, key, value string
+		allowed             []string
+	}{
+		{"target", "csr_policy", j.Target.CSRPolicy, []string{CSRPolicyEnforce, CSRPolicyWarn, CSRPolicyOff}},
+		{"target", "key_type", j.Target.KeyType, []string{KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519}},
+		{"ca", "ca_chain_source", j.Ca.CAChainSource, []string{CAChainSourceFile, CAChainSourceEJBCA}},
+	} {
+		problems = append(problems, j.CheckEnum(e.section, e.key, e.value, e.allowed...)...)
+	}
+	if _, err := NormalizeCurve(j.Target.KeyCurve); err != nil {
+		add("target", "key_curve", "%v", err)
+	}
+	if j.Target.KeySize < 0 {
+		add("target", "key_size", "negative key size %d", j.Target.KeySize)
+	}
+
+	// script variables
+	for _, key := range scriptKeys {
+		script := FieldByIniTag(j.Target, key).String()
+		for _, msg := range j.checkScriptVars(script) {
+			add("target", key, "%s", msg)
+		}
+	}
+
+	return problems
+}
+
+
+/**
+ *  CheckEnum checks that a value is empty (default) or one of the allowed values (case-insensitive).
+ *
+ *  Params:
+ *    - section, key: INI section and key of the value.
+ *    - value: configured value.
+ *    - allowed: allowed values.
+ *
+ *  Returns:
+ *    - []ConfigProblem: one problem if the value is not allowed, nil otherwise.
+ *
+ */
+func (j *Job) CheckEnum(section, key, value string, allowed ...string) []ConfigProblem {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return nil
+		}
+	}
+	return []ConfigProblem{j.Problemf(section, key, "invalid value %q (allowed: %s)", value, strings.Join(allowed, ", "))}
+}
+
+
+/**
+ *  checkScriptVars checks the ${...} references of a script the same way
+ *  GetShellVariables resolves them.
+ *
+ *  Returns:
+ *    - []string: one message per unresolvable reference.
+ *
+ */
+func (j *Job) checkScriptVars(script string) []string {
+
+	var msgs []string
+	seen := map[string]bool{}
+	for _, m := range reExtracatVar.FindAllStringSubmatch(script, -1) {
+		name := m[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		parts := reGetPrefixAndVar.FindStringSubmatch(name)
+		if parts == nil {
+			msgs = append(msgs, fmt.Sprintf("${%s} is not of the form ${<section>_<key>}", name))
+			continue
+		}
+		section, key := parts[1], parts[2]
+
+		var src any
+		switch section {
+			case "job":
+				src = j
+			case "ca":
+				src = j.Ca
+			case "verify":
+				src = j.Verify
+			case "target":
+				src = j.Target
+			case "host":
+				if j.HostVars == nil {
+					msgs = append(msgs, fmt.Sprintf("${%s}: host list variables are only available in templated jobs", name))
+				} else if _, ok := j.HostVars[strings.ToLower(key)]; !ok {
+					msgs = append(msgs, fmt.Sprintf("${%s} is no column of the host list", name))
+				}
+				continue
+			default:
+				msgs = append(msgs, fmt.Sprintf("${%s}: unknown section %q (allowed: job, ca, target, verify, host)", name, section))
+				continue
+		}
+
+		if !hasIniTag(src, key) {
+			msgs = append(msgs, fmt.Sprintf("${%s}: [%s] has no key %q", name, section, key))
+		} else if !scriptAllowed(src, key) {
+			msgs = append(msgs, fmt.Sprintf("${%s} is a secret and not available to scripts", name))
+		}
+	}
+	return msgs
+}
+
+
+/**
+ *  hasIniTag reports whether a struct has a field with the given ini tag (or var tag, see varName).
+ *
+ */
+func hasIniTag(v any, iniName string) bool {
+	rt := reflect.TypeOf(v)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		if varName(rt.Field(i)) == iniName {
+			return true
+		}
+	}
+	return false
+}
+
+
+/**
+ *  checkHostPort checks a "host:port" address.
+ *
+ */
+func checkHostPort(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("address %q: host missing", addr)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("address %q: port out of range 1-65535", addr)
+	}
+	return nil
+}
+
+
+/**
+ *  expandHomeDir replaces a leading "~/" with the home directory of the current user.
+ *
+ */
+func expandHomeDir(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
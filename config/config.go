@@ -16,6 +16,8 @@ package config
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/tseiman/embed-cert-manager/logger"
 )
@@ -23,6 +25,7 @@ import (
 
 /**
  *  Load reads all job configuration files from the given directory and populates c.Jobs. 
+ *  CA profiles and [target] defaults are taken from embed-cert-manager.conf next to the directory.
  * Params:
  *   - configPath: directory containing job *.conf files (INI format).
  * Returns:
@@ -40,16 +43,38 @@ func (c *Config) Load(configPath string) (error) {
 
 
 
+	global, err := loadGlobalINI(configPath)
+	if err != nil {
+		logger.Errorln(err)
+		return err
+	}
+
 	var jobs []Job
+	var problems []ConfigProblem
+	// the job name identifies the End Entity, the job state and the daemon schedule,
+	// so it must be unique: later jobs with a known name are not loaded
+	seen := map[string]string{}
 	for _, path := range files {
-		job := loadOneJobINI(path)
-		if job != nil {
-			jobs = append(jobs, *job)
+		j, p := loadOneJobINI(path, global, c.IncludeDisabled)
+		problems = append(problems, p...)
+		for _, job := range j {
+			if !job.Enabled {
+				jobs = append(jobs, job) // never run, may share the name of an enabled job
+				continue
+			}
+			name := strings.ToLower(job.Name)
+			if first, ok := seen[name]; ok {
+				problems = append(problems, logProblems(newProblem(path, job.Name, "job", "",
+					fmt.Sprintf("duplicate job name, already defined in %s", first)))...)
+				continue
+			}
+			seen[name] = path
+			jobs = append(jobs, job)
 		}
-
 	}
 
 	c.Jobs = jobs
+	c.Problems = problems
 
 
 	return nil
@@ -87,3 +112,206 @@ func (j *Job) GetCertSetCmd() (string) {
 	return cmd
 }
 
+
+/**
+ *  Log returns a logger which prefixes every message with the job name.
+ *  This keeps the output readable when several jobs run in parallel.
+ *  Returns:
+ *   - logger.Prefixed: job specific logger.
+ * */
+func (j *Job) Log() (logger.Prefixed) {
+	return logger.WithPrefix(j.Name)
+}
+
+/**
+ *  HasTag reports whether the job has the given tag ([job] tags), case-insensitive.
+ *  Params:
+ *   - tag: tag to look for.
+ *  Returns:
+ *   - bool: true if the job has the tag.
+ * */
+func (j *Job) HasTag(tag string) (bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, t := range j.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ *  secretValues returns the values of all fields tagged with `secret:"true"`
+ *  in the [ca] and [target] configuration which are set.
+ * */
+func (j *Job) secretValues() ([]string) {
+	var out []string
+	for _, v := range []any{j.Ca, j.Target} {
+		rv := reflect.ValueOf(v)
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if rt.Field(i).Tag.Get("secret") != "true" || rv.Field(i).Kind() != reflect.String {
+				continue
+			}
+			if secret := rv.Field(i).String(); secret != "" {
+				out = append(out, secret)
+			}
+		}
+	}
+	return out
+}
+
+/**
+ *  MaskSecrets replaces the values of all fields tagged with `secret:"true"`
+ *  in the [ca] and [target] configuration by "********".
+ *  Params:
+ *   - s: text which may contain secret values (e.g. a rendered script).
+ *  Returns:
+ *   - string: text with secrets masked.
+ * */
+func (j *Job) MaskSecrets(s string) (string) {
+	for _, secret := range j.secretValues() {
+		// quoted form first, it differs from the raw value if the secret contains a quote
+		s = strings.ReplaceAll(s, ShellQuote(secret), "'********'")
+		s = strings.ReplaceAll(s, secret, "********")
+	}
+	return s
+}
+
+/**
+ *  RegisterSecrets hands the current secret values of the job to the logger,
+ *  which masks them in every log line. Must be called again when a secret changes.
+ * */
+func (j *Job) RegisterSecrets() {
+	for _, secret := range j.secretValues() {
+		logger.AddSecret(ShellQuote(secret))
+		logger.AddSecret(secret)
+	}
+}
+
+/**
+ *  UnregisterSecrets removes the current secret values of the job from the logger,
+ *  e.g. when the job configuration is replaced by a reload.
+ * */
+func (j *Job) UnregisterSecrets() {
+	for _, secret := range j.secretValues() {
+		logger.RemoveSecret(ShellQuote(secret))
+		logger.RemoveSecret(secret)
+	}
+}
+
+/**
+ *  ForgetPrivateKey drops a locally generated private key after the job has finished
+ *  and removes it from the secrets masked by the logger.
+ * */
+func (j *Job) ForgetPrivateKey() {
+	if j.Target.PrivateKey == "" {
+		return
+	}
+	logger.RemoveSecret(ShellQuote(j.Target.PrivateKey))
+	logger.RemoveSecret(j.Target.PrivateKey)
+	j.Target.PrivateKey = ""
+}
+
+
+/**
+ *  ManageEndEntity reports whether profiles and CA of the End Entity are set from the
+ *  job ([ca] cert_profile, ee_profile, ca_name) before a certificate is requested.
+ * */
+func (j *Job) ManageEndEntity() bool {
+	return j.Ca.CertProfile != "" || j.Ca.EEProfile != "" || j.Ca.CAName != ""
+}
+
+
+// values of [ca] ca_chain_source
+const (
+	CAChainSourceFile  = "file"
+	CAChainSourceEJBCA = "ejbca"
+)
+
+
+/**
+ *  FetchCAChain reports whether the CA chain is fetched from EJBCA at issuance time
+ *  instead of being read from ca_cert.
+ * */
+func (j *Job) FetchCAChain() bool {
+	return strings.ToLower(strings.TrimSpace(j.Ca.CAChainSource)) == CAChainSourceEJBCA
+}
+
+
+// suffix of the backup copies made by GetBackupCmd
+const BackupSuffix = ".ecm-bak"
+
+/**
+ *  RollbackEnabled reports whether a failed install should be rolled back,
+ *  which is the case if backups are enabled or a rollback_command is configured.
+ *  Returns:
+ *   - bool: true if rollback is enabled.
+ * */
+func (j *Job) RollbackEnabled() (bool) {
+	return j.Target.Backup || strings.TrimSpace(j.Target.RollbackCommand) != ""
+}
+
+/**
+ *  backupFiles returns the target files which are backed up before an install.
+ * */
+func (j *Job) backupFiles() ([]string) {
+	var files []string
+	for _, f := range []string{j.Target.CertPath, j.Target.KeyPath, j.Target.ChainPath} {
+		if strings.TrimSpace(f) != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+/**
+ *  GetBackupCmd builds the shell command which copies the existing certificate and key
+ *  on the target to "<file>.ecm-bak" before a new key or certificate is requested.
+ *  Backups of earlier runs are removed first, so the rollback restores only the files
+ *  backed up by this run. Files which do not exist yet are skipped.
+ *  Returns:
+ *   - string: ready-to-run shell command.
+ * */
+func (j *Job) GetBackupCmd() (string) {
+	cmd := ""
+	for _, f := range j.backupFiles() {
+		q := ShellQuote(f)
+		bq := ShellQuote(f + BackupSuffix)
+		cmd += "rm -f -- " + bq + " || exit 1\n"
+		cmd += "if [ -f " + q + " ]; then cp -p -- " + q + " " + bq + " || exit 1; fi\n"
+	}
+	return cmd
+}
+
+/**
+ *  GetRollbackCmd builds the shell command used to restore the previous certificate material.
+ *  If a rollback_command is configured it is used (with the same variables as the other commands),
+ *  otherwise the built-in rollback restores the backups made by GetBackupCmd and runs reload_command.
+ *  Returns:
+ *   - string: ready-to-run shell command.
+ * */
+func (j *Job) GetRollbackCmd() (string) {
+
+	if strings.TrimSpace(j.Target.RollbackCommand) != "" {
+		j.Target.CommandEnvList = extractVars(j.Target.RollbackCommand)
+		cmd := j.Target.GetShellVariables(j)
+		cmd += j.Target.RollbackCommand
+		return cmd
+	}
+
+	cmd := "rc=0\n"
+	for _, f := range j.backupFiles() {
+		q := ShellQuote(f)
+		bq := ShellQuote(f + BackupSuffix)
+		cmd += "if [ -f " + bq + " ]; then cp -p -- " + bq + " " + q + " || rc=1; fi\n"
+	}
+	if strings.TrimSpace(j.Target.ReloadCommand) != "" {
+		j.Target.CommandEnvList = extractVars(j.Target.ReloadCommand)
+		cmd += j.Target.GetShellVariables(j)
+		cmd += j.Target.ReloadCommand + "\n"
+		cmd += "[ $? -eq 0 ] || rc=1\n"
+	}
+	cmd += "exit $rc\n"
+	return cmd
+}
@@ -0,0 +1,432 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config validates CSRs received from a target against the policy
+ *  declared in the job (CN, subjectAltName, key type/size/curve), so a
+ *  misconfigured or compromised device cannot get a certificate for other names.
+ *
+ */
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// smallest RSA key accepted in a CSR and created locally
+const MinRSAKeySize = 2048
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidCommonName     = asn1.ObjectIdentifier{2, 5, 4, 3}
+)
+
+// short names of name attributes (subject, dirName SAN), others are written as OID
+var dirNameAttributes = map[string]string{
+	"2.5.4.3":  "CN",
+	"2.5.4.5":  "serialNumber",
+	"2.5.4.6":  "C",
+	"2.5.4.7":  "L",
+	"2.5.4.8":  "ST",
+	"2.5.4.9":  "street",
+	"2.5.4.10": "O",
+	"2.5.4.11": "OU",
+	"2.5.4.17": "postalCode",
+}
+
+// key types usable in [target] key_type
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// ECDSA curves usable in [target] key_curve
+const (
+	CurveP256 = "P-256"
+	CurveP384 = "P-384"
+	CurveP521 = "P-521"
+)
+
+// OpenSSL names of the curves, accepted as aliases
+var curveAliases = map[string]string{
+	"P-256":      CurveP256,
+	"PRIME256V1": CurveP256,
+	"SECP256R1":  CurveP256,
+	"P-384":      CurveP384,
+	"SECP384R1":  CurveP384,
+	"P-521":      CurveP521,
+	"SECP521R1":  CurveP521,
+}
+
+// values of [target] csr_policy
+const (
+	CSRPolicyEnforce = "enforce"
+	CSRPolicyWarn    = "warn"
+	CSRPolicyOff     = "off"
+)
+
+
+/**
+ *  CSRPolicy returns the effective csr_policy of the job ("enforce" if not set).
+ *
+ */
+func (j *Job) CSRPolicy() (string) {
+	p := strings.ToLower(strings.TrimSpace(j.Target.CSRPolicy))
+	if p == "" {
+		return CSRPolicyEnforce
+	}
+	return p
+}
+
+
+/**
+ *  CheckCSRPolicy validates a PEM encoded CSR against the job:
+ *    - the subject must only hold the CN, which must be the job host,
+ *    - DNS/IP/URI/email SANs must equal the declared subjectAltName, SANs of other
+ *      types (RID, dirName, otherName, ...) must be declared exactly in subjectAltName,
+ *    - the key must be RSA >= 2048 bit, ECDSA P-256/P-384/P-521 or Ed25519; if key_type is
+ *      set the key algorithm must match, key_size is the minimum RSA size and key_curve
+ *      the required ECDSA curve.
+ *
+ *  Params:
+ *    - csrPEM: CSR in PEM format.
+ *
+ *  Returns:
+ *    - error: nil if the CSR complies, otherwise all violations.
+ *
+ */
+func (j *Job) CheckCSRPolicy(csrPEM string) (error) {
+
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return fmt.Errorf("CSR PEM decode: no PEM block found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("CSR parse: %w", err)
+	}
+
+	want := j.Target.SANs
+	var problems []string
+
+	if !strings.EqualFold(csr.Subject.CommonName, j.Name) {
+		problems = append(problems, fmt.Sprintf("CN %q, expected %q", csr.Subject.CommonName, j.Name))
+	}
+	cns := 0
+	for _, attr := range csr.Subject.Names {
+		if !attr.Type.Equal(oidCommonName) {
+			problems = append(problems, fmt.Sprintf("subject attribute %s=%v not allowed (only CN)", attributeName(attr.Type), attr.Value))
+		} else if cns++; cns > 1 {
+			problems = append(problems, "subject has more than one CN")
+		}
+	}
+
+	ips := func(in []net.IP) []string {
+		out := make([]string, len(in))
+		for i, ip := range in {
+			out[i] = ip.String()
+		}
+		return out
+	}
+	uris := func(csr *x509.CertificateRequest) []string {
+		out := make([]string, len(csr.URIs))
+		for i, u := range csr.URIs {
+			out[i] = u.String()
+		}
+		return out
+	}
+	wantURIs := make([]string, len(want.URI))
+	for i, u := range want.URI {
+		wantURIs[i] = u.String()
+	}
+
+	problems = append(problems, compareNames("DNS", csr.DNSNames, want.DNS)...)
+	problems = append(problems, compareNames("IP", ips(csr.IPAddresses), ips(want.IP))...)
+	problems = append(problems, compareNames("URI", uris(csr), wantURIs)...)
+	problems = append(problems, compareNames("email", csr.EmailAddresses, want.Email)...)
+
+	other, err := otherSANs(csr)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	declared := map[string]bool{}
+	for _, entry := range want.Other {
+		declared[canonicalSAN(entry)] = true
+	}
+	for _, entry := range other {
+		if !declared[entry] {
+			problems = append(problems, fmt.Sprintf("SAN not allowed: %s", entry))
+		}
+	}
+
+	if p := j.checkCSRKey(csr); p != "" {
+		problems = append(problems, p)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("CSR violates job policy: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+
+/**
+ *  checkCSRKey checks the public key of a CSR against key_type, key_size and key_curve.
+ *
+ *  Returns:
+ *    - string: description of the violation, empty if the key complies.
+ *
+ */
+func (j *Job) checkCSRKey(csr *x509.CertificateRequest) (string) {
+
+	// without key_type any of the supported key types is accepted
+	keyType := strings.ToLower(strings.TrimSpace(j.Target.KeyType))
+	wrongType := func(got string) bool {
+		return keyType != "" && keyType != got
+	}
+
+	switch pub := csr.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if wrongType(KeyTypeRSA) {
+				return fmt.Sprintf("key type rsa, expected %s", keyType)
+			}
+			min := MinRSAKeySize
+			if j.Target.KeySize > min {
+				min = j.Target.KeySize
+			}
+			if pub.N.BitLen() < min {
+				return fmt.Sprintf("RSA key size %d, expected at least %d", pub.N.BitLen(), min)
+			}
+		case *ecdsa.PublicKey:
+			if wrongType(KeyTypeECDSA) {
+				return fmt.Sprintf("key type ecdsa, expected %s", keyType)
+			}
+			name := pub.Curve.Params().Name
+			if _, err := NormalizeCurve(name); err != nil {
+				return fmt.Sprintf("ECDSA curve %s not allowed (allowed: %s, %s, %s)", name, CurveP256, CurveP384, CurveP521)
+			}
+			curve, err := NormalizeCurve(j.Target.KeyCurve)
+			if err != nil {
+				return err.Error()
+			}
+			if curve != "" && name != curve {
+				return fmt.Sprintf("ECDSA curve %s, expected %s", name, curve)
+			}
+		case ed25519.PublicKey:
+			if wrongType(KeyTypeEd25519) {
+				return fmt.Sprintf("key type ed25519, expected %s", keyType)
+			}
+		default:
+			return fmt.Sprintf("unsupported key type %T", pub)
+	}
+	return ""
+}
+
+
+/**
+ *  otherSANs returns the SANs of a CSR which are not DNS, IP, URI or email, written
+ *  like the declared entries of subjectAltName (see canonicalSAN):
+ *  "RID:1.2.3.4", "otherName:<oid>;UTF8:<value>", "dirName:/O=Org/CN=name".
+ *
+ *  Returns:
+ *    - []string: SAN entries of other types.
+ *    - error: non-nil if the subjectAltName extension cannot be parsed.
+ *
+ */
+func otherSANs(csr *x509.CertificateRequest) ([]string, error) {
+
+	var out []string
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+
+		var seq asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &seq); err != nil || seq.Tag != asn1.TagSequence {
+			return nil, fmt.Errorf("CSR subjectAltName extension invalid")
+		}
+
+		for rest := seq.Bytes; len(rest) > 0; {
+			var gn asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &gn); err != nil || gn.Class != asn1.ClassContextSpecific {
+				return nil, fmt.Errorf("CSR subjectAltName extension invalid")
+			}
+
+			// GeneralName tags, RFC 5280 4.2.1.6
+			switch gn.Tag {
+				case 1, 2, 6, 7: // email, DNS, URI, IP: compared above
+				case 0:
+					out = append(out, "otherName:" + otherNameString(gn.Bytes))
+				case 4:
+					out = append(out, "dirName:" + dirNameString(gn.Bytes))
+				case 8:
+					// implicitly tagged OID, restore the universal tag to decode it
+					var oid asn1.ObjectIdentifier
+					der, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagOID, Bytes: gn.Bytes})
+					if _, err := asn1.Unmarshal(der, &oid); err != nil {
+						out = append(out, "RID:" + hex.EncodeToString(gn.Bytes))
+					} else {
+						out = append(out, "RID:" + oid.String())
+					}
+				default:
+					out = append(out, fmt.Sprintf("GeneralName[%d]:%s", gn.Tag, hex.EncodeToString(gn.Bytes)))
+			}
+		}
+	}
+	return out, nil
+}
+
+
+/**
+ *  otherNameString renders an otherName SAN as "<oid>;UTF8:<value>" (OpenSSL notation),
+ *  values which are no UTF8String as "<oid>;DER:<hex>".
+ *
+ */
+func otherNameString(b []byte) (string) {
+	var oid asn1.ObjectIdentifier
+	rest, err := asn1.Unmarshal(b, &oid)
+	if err != nil {
+		return "DER:" + hex.EncodeToString(b)
+	}
+	var wrapped, value asn1.RawValue
+	if _, err := asn1.Unmarshal(rest, &wrapped); err == nil {
+		if _, err := asn1.Unmarshal(wrapped.Bytes, &value); err == nil && value.Tag == asn1.TagUTF8String {
+			return oid.String() + ";UTF8:" + string(value.Bytes)
+		}
+	}
+	return oid.String() + ";DER:" + hex.EncodeToString(rest)
+}
+
+
+/**
+ *  dirNameString renders a dirName SAN in the OpenSSL one line notation, e.g. "/O=Org/CN=name".
+ *
+ */
+func dirNameString(b []byte) (string) {
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(b, &rdns); err != nil {
+		return "DER:" + hex.EncodeToString(b)
+	}
+	var sb strings.Builder
+	for _, rdn := range rdns {
+		for _, attr := range rdn {
+			fmt.Fprintf(&sb, "/%s=%v", attributeName(attr.Type), attr.Value)
+		}
+	}
+	return sb.String()
+}
+
+
+/**
+ *  attributeName returns the short name of a name attribute, e.g. "O", or its OID if unknown.
+ *
+ */
+func attributeName(oid asn1.ObjectIdentifier) (string) {
+	if name, ok := dirNameAttributes[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
+
+
+/**
+ *  canonicalSAN writes a declared RID, dirName or otherName entry of subjectAltName
+ *  like otherSANs does: type in its usual spelling, value without surrounding spaces.
+ *
+ */
+func canonicalSAN(entry string) (string) {
+	kind, value, _ := strings.Cut(entry, ":")
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+		case "rid":
+			kind = "RID"
+		case "dirname":
+			kind = "dirName"
+		case "othername":
+			kind = "otherName"
+	}
+	return kind + ":" + strings.TrimSpace(value)
+}
+
+
+/**
+ *  compareNames compares requested and declared names of one SAN type (case-insensitive).
+ *
+ *  Returns:
+ *    - []string: descriptions of names which are requested but not declared and vice versa.
+ *
+ */
+func compareNames(kind string, got, want []string) ([]string) {
+	set := func(in []string) map[string]bool {
+		m := map[string]bool{}
+		for _, s := range in {
+			m[strings.ToLower(s)] = true
+		}
+		return m
+	}
+	gotSet, wantSet := set(got), set(want)
+
+	var extra, missing []string
+	for s := range gotSet {
+		if !wantSet[s] {
+			extra = append(extra, s)
+		}
+	}
+	for s := range wantSet {
+		if !gotSet[s] {
+			missing = append(missing, s)
+		}
+	}
+	sort.Strings(extra)
+	sort.Strings(missing)
+
+	var problems []string
+	if len(extra) > 0 {
+		problems = append(problems, fmt.Sprintf("%s SAN not allowed: %s", kind, strings.Join(extra, ", ")))
+	}
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("%s SAN missing: %s", kind, strings.Join(missing, ", ")))
+	}
+	return problems
+}
+
+
+/**
+ *  NormalizeCurve maps an ECDSA curve name of [target] key_curve to its NIST name,
+ *  e.g. "prime256v1" or "secp256r1" to "P-256" (case-insensitive).
+ *
+ *  Params:
+ *    - name: configured curve name.
+ *
+ *  Returns:
+ *    - string: NIST curve name, empty if name is empty (default curve).
+ *    - error: non-nil if the curve is unknown.
+ *
+ */
+func NormalizeCurve(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	if curve, ok := curveAliases[strings.ToUpper(name)]; ok {
+		return curve, nil
+	}
+	return "", fmt.Errorf("unknown curve %q (allowed: %s, %s, %s)", name, CurveP256, CurveP384, CurveP521)
+}
@@ -0,0 +1,89 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config disables job files in place. The file is edited line by line
+ *  instead of re-writing it with the INI library, so comments and multi-line
+ *  scripts stay untouched.
+ *
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	reSection = regexp.MustCompile(`^\s*\[\s*([^\]]+?)\s*\]`)
+	reEnabled = regexp.MustCompile(`(?i)^(\s*enabled\s*=\s*)\S.*$`)
+)
+
+
+/**
+ *  DisableJobFile sets "enabled = false" in the [job] section of a job file.
+ *  The file is replaced atomically and keeps its permissions.
+ *
+ *  Params:
+ *    - path: job file to disable.
+ *
+ *  Returns:
+ *    - error: non-nil if the file cannot be read/written or has no "enabled" key in [job].
+ *
+ */
+func DisableJobFile(path string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	section := ""
+	found := false
+	for i, line := range lines {
+		if m := reSection.FindStringSubmatch(line); m != nil {
+			section = strings.ToLower(m[1])
+			continue
+		}
+		if section == "job" && reEnabled.MatchString(line) {
+			lines[i] = reEnabled.ReplaceAllString(line, "${1}false")
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s: no \"enabled\" key in [job] section", path)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ecm-job-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strings.Join(lines, "\n")); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,138 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config loads the optional global configuration file, which holds
+ *  named CA profiles ([ca_profile.<name>]) and [defaults] for [target] keys shared
+ *  by all jobs. Job files only override what differs.
+ *
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+const (
+	// name of the global configuration file, next to the jobs.d folder
+	GlobalConfigFile = "embed-cert-manager.conf"
+
+	globalDefaultsSection  = "defaults"
+	globalCAProfilePrefix  = "ca_profile."
+)
+
+/**
+ *  Global holds the parsed global configuration file.
+ *  A nil *Global behaves like an empty file.
+ *
+ */
+type Global struct {
+	path string
+	ini  *ini.File
+}
+
+
+/**
+ *  loadGlobalINI loads the global configuration file next to the jobs.d folder.
+ *
+ *  Params:
+ *    - jobsDir: jobs.d folder.
+ *
+ *  Returns:
+ *    - *Global: parsed file, nil if it does not exist.
+ *    - error: non-nil if the file exists but cannot be parsed.
+ *
+ */
+func loadGlobalINI(jobsDir string) (*Global, error) {
+
+	path := filepath.Join(filepath.Dir(filepath.Clean(jobsDir)), GlobalConfigFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		logger.Debugf("no global configuration %s\n", path)
+		return nil, nil
+	}
+
+	f, err := ini.LoadSources(ini.LoadOptions{
+		Loose:       true,
+		Insensitive: true,
+	}, path)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", path, err)
+	}
+
+	g := &Global{path: path, ini: f}
+	logger.Infof("Loaded global configuration %s (CA profiles: %s)\n", path, strings.Join(g.caProfiles(), ", "))
+	return g, nil
+}
+
+
+/**
+ *  caProfiles returns the names of all CA profiles defined in the global file.
+ *
+ */
+func (g *Global) caProfiles() []string {
+	if g == nil {
+		return nil
+	}
+	var names []string
+	for _, sec := range g.ini.Sections() {
+		if name, ok := strings.CutPrefix(sec.Name(), globalCAProfilePrefix); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+
+/**
+ *  applyDefaults maps the [defaults] section onto a job's target configuration.
+ *  Must be called before the job's own [target] section is mapped, so the job overrides.
+ *
+ */
+func (g *Global) applyDefaults(t *Target) error {
+	if g == nil || !g.ini.HasSection(globalDefaultsSection) {
+		return nil
+	}
+	if err := g.ini.Section(globalDefaultsSection).MapTo(t); err != nil {
+		return fmt.Errorf("%q: map [%s]: %w", g.path, globalDefaultsSection, err)
+	}
+	return nil
+}
+
+
+/**
+ *  applyCAProfile maps the named CA profile onto a job's CA configuration.
+ *  Must be called before the job's own [ca] section is mapped, so the job overrides.
+ *
+ *  Params:
+ *    - name: profile name (case-insensitive).
+ *    - ca: CA configuration to fill.
+ *
+ *  Returns:
+ *    - error: non-nil if the profile is not defined or cannot be mapped.
+ *
+ */
+func (g *Global) applyCAProfile(name string, ca *Ca) error {
+	section := globalCAProfilePrefix + strings.ToLower(strings.TrimSpace(name))
+	if g == nil {
+		return fmt.Errorf("CA profile %q: no %s found", name, GlobalConfigFile)
+	}
+	if !g.ini.HasSection(section) {
+		return fmt.Errorf("CA profile %q not defined in %q (defined: %s)", name, g.path, strings.Join(g.caProfiles(), ", "))
+	}
+	if err := g.ini.Section(section).MapTo(ca); err != nil {
+		return fmt.Errorf("%q: map [%s]: %w", g.path, section, err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config expands templated job files: a job file with a host list
+ *  ([job] hosts or hosts_file, CSV with a header line, or an inventory) becomes
+ *  one job per row.
+ *  The columns of a row are available as ${host_<column>}.
+ *
+ */
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// column holding the job host, required in every host list
+const hostColumn = "host"
+
+var (
+	reHostColumn = regexp.MustCompile(`^[a-z0-9][-a-z0-9_]*$`)
+	reHostVar    = regexp.MustCompile(`\$\{(job_host|host_[-a-zA-Z0-9_]+)\}`)
+)
+
+
+/**
+ *  hostRows reads the host list of a job file from [job] hosts (inline CSV),
+ *  [job] hosts_file (CSV file, relative to the job file) or [job] inventory
+ *  (see inventory.go).
+ *
+ *  Params:
+ *    - sec: [job] section of the job file.
+ *    - path: job file, used to resolve relative file names.
+ *
+ *  Returns:
+ *    - []map[string]string: one map column -> value per row, nil if the job has no host list.
+ *    - error: non-nil if the list cannot be read, has no "host" column or invalid rows.
+ *
+ */
+func hostRows(sec *ini.Section, path string) ([]map[string]string, error) {
+
+	inline := strings.TrimSpace(sec.Key("hosts").String())
+	file := strings.TrimSpace(sec.Key("hosts_file").String())
+	inventory := strings.TrimSpace(sec.Key("inventory").String())
+
+	set := 0
+	for _, v := range []string{inline, file, inventory} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("[job] hosts, hosts_file and inventory are mutually exclusive")
+	}
+
+	switch {
+		case inline != "":
+			return parseHostCSV(inline, "[job] hosts")
+		case file != "":
+			file = relativeTo(path, file)
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("[job] hosts_file: %w", err)
+			}
+			return parseHostCSV(string(b), file)
+		case inventory != "":
+			return loadInventory(sec, path, inventory)
+	}
+	return nil, nil
+}
+
+
+/**
+ *  parseHostCSV parses a CSV host list with a header line.
+ *
+ *  Params:
+ *    - data: CSV text, lines starting with "#" are ignored.
+ *    - src: name of the source used in error messages.
+ *
+ *  Returns:
+ *    - []map[string]string: one map column -> value per row.
+ *    - error: non-nil on CSV errors, a missing header or invalid rows.
+ *
+ */
+func parseHostCSV(data, src string) ([]map[string]string, error) {
+
+	r := csv.NewReader(strings.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s: expected a header line and at least one host", src)
+	}
+
+	header := records[0]
+	hasHost := false
+	for _, col := range header {
+		hasHost = hasHost || strings.ToLower(strings.TrimSpace(col)) == hostColumn
+	}
+	if !hasHost {
+		return nil, fmt.Errorf("%s: no %q column", src, hostColumn)
+	}
+
+	var rows []map[string]string
+	for _, rec := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			row[col] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return checkHostRows(rows, src)
+}
+
+
+/**
+ *  checkHostRows normalizes host list rows (lower case column names, trimmed values)
+ *  and checks that every row has a unique, non-empty host.
+ *
+ *  Params:
+ *    - rows: rows as read from the source.
+ *    - src: name of the source used in error messages.
+ *
+ *  Returns:
+ *    - []map[string]string: normalized rows.
+ *    - error: non-nil on invalid column names, empty or duplicate hosts.
+ *
+ */
+func checkHostRows(rows []map[string]string, src string) ([]map[string]string, error) {
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no hosts found", src)
+	}
+
+	seen := map[string]bool{}
+	out := make([]map[string]string, 0, len(rows))
+	for n, in := range rows {
+		row := make(map[string]string, len(in))
+		for col, v := range in {
+			col = strings.ToLower(strings.TrimSpace(col))
+			if !reHostColumn.MatchString(col) {
+				return nil, fmt.Errorf("%s: invalid column name %q (allowed: a-z, 0-9, - and _)", src, col)
+			}
+			row[col] = strings.TrimSpace(v)
+		}
+		host := row[hostColumn]
+		if host == "" {
+			return nil, fmt.Errorf("%s: entry %d: empty host", src, n+1)
+		}
+		if seen[host] {
+			return nil, fmt.Errorf("%s: entry %d: duplicate host %q", src, n+1, host)
+		}
+		seen[host] = true
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+
+/**
+ *  relativeTo resolves a file name relative to the directory of a job file.
+ *
+ */
+func relativeTo(jobFile, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(jobFile), name)
+}
+
+
+/**
+ *  expandHostVars replaces ${job_host} and ${host_<column>} in a configuration value
+ *  (e.g. subjectAltName) by the values of the job.
+ *
+ *  Params:
+ *    - s: value to expand.
+ *    - j: job providing the host and the host list columns.
+ *
+ *  Returns:
+ *    - string: expanded value.
+ *    - error: non-nil if a referenced column does not exist.
+ *
+ */
+func expandHostVars(s string, j *Job) (string, error) {
+	var missing []string
+	out := reHostVar.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if name == "job_host" {
+			return j.Name
+		}
+		v, ok := j.HostVars[strings.ToLower(strings.TrimPrefix(name, "host_"))]
+		if !ok {
+			missing = append(missing, ref)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unknown host list column in %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
@@ -15,6 +15,7 @@ package config
  */
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -66,16 +67,21 @@ func (c *Config) getFiles(dir string) ([]string) {
 
 /**
  *  loadOneJobINI loads and parses a single job INI file.
- *  It maps INI sections and keys into a Job structure.
+ *  It maps INI sections and keys into Job structures: one job, or one job per row
+ *  if the file declares a host list ([job] hosts, hosts_file or inventory).
+ *  Problems which prevent a job from being loaded are logged and returned.
  *
  *  Params:
  *    - path: filesystem path to the job *.conf file.
+ *    - global: global configuration providing CA profiles and [target] defaults (may be nil).
+ *    - includeDisabled: load the jobs of a disabled job file as well.
  *
  *  Returns:
- *    - *Job: parsed job configuration, or nil if parsing failed.
+ *    - []Job: parsed job configurations, empty if parsing failed or the job is disabled.
+ *    - []ConfigProblem: problems of the jobs which could not be loaded.
  *
  */
-func loadOneJobINI(path string) (*Job) {
+func loadOneJobINI(path string, global *Global, includeDisabled bool) ([]Job, []ConfigProblem) {
 	// Loose: unknown keys are ok (useful for comments and old stuff)
 	// Insensitive: keys case-insensitive
 	iniCfg, err := ini.LoadSources(ini.LoadOptions{
@@ -83,25 +89,71 @@ func loadOneJobINI(path string) (*Job) {
 		Insensitive: true,
 	}, path)
 	if err != nil {
-		logger.Infof("parse ini %q: %v", path, err)
-		return nil
+		return nil, logProblems(newProblem(path, "", "", "", fmt.Sprintf("parse ini: %v", err)))
 	}
 
-	var j Job
+	// a disabled job is skipped before its host list or inventory is read
+	raw := iniCfg.Section("job").Key("enabled").String()
+	enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, logProblems(newProblem(path, "", "job", "enabled", fmt.Sprintf("invalid boolean value %q, job disabled", raw)))
+	}
+	if !enabled && !includeDisabled {
+		logger.Infof("Job file <%s> not enabled - skipping\n", path)
+		return nil, nil
+	}
 
-	secJob := iniCfg.Section("job")
+	rows, err := hostRows(iniCfg.Section("job"), path)
+	if err != nil {
+		return nil, logProblems(newProblem(path, "", "job", "", err.Error()))
+	}
+	if rows == nil {
+		rows = []map[string]string{nil}
+	}
 
-	raw := secJob.Key("enabled").String()
-	b, err := strconv.ParseBool(strings.TrimSpace(raw))
-	if err != nil {	
-		logger.Errorf("invalid boolean value for job enable parameter %q: %v, disable DISABLE JOB", raw, err)
-		j.Enabled= false
-		return nil
+	var (
+		jobs     []Job
+		problems []ConfigProblem
+		secrets  = secretCache{} // e.g. an exec: password is run once for all rows
+	)
+	for _, row := range rows {
+		j, p := buildJob(path, iniCfg, global, row, secrets)
+		if j != nil {
+			j.Enabled = enabled
+			jobs = append(jobs, *j)
+		}
+		problems = append(problems, logProblems(p...)...)
 	}
+	return jobs, problems
+}
+
+
+/**
+ *  buildJob maps a parsed job file into a Job.
+ *  All problems found in the values are collected, so they can be fixed at once.
+ *
+ *  Params:
+ *    - path: filesystem path of the job file.
+ *    - iniCfg: parsed job file.
+ *    - global: global configuration providing CA profiles and [target] defaults (may be nil).
+ *    - row: host list row (column -> value) of a templated job, nil otherwise.
+ *    - secrets: secret references already resolved for this file.
+ *
+ *  Returns:
+ *    - *Job: job configuration, or nil if the job is invalid.
+ *    - []ConfigProblem: problems which make the job invalid.
+ *
+ */
+func buildJob(path string, iniCfg *ini.File, global *Global, row map[string]string, secrets secretCache) (*Job, []ConfigProblem) {
+
+	var j Job
+	j.File = path
+
+	secJob := iniCfg.Section("job")
 
 
 	j.Name = strings.TrimSpace(secJob.Key("host").String())
-	if j.Name == "" {
+	if j.Name == "" && row == nil {
 		// Fallback: filename without ending
 		base := filepath.Base(path)
 		j.Name = strings.TrimSuffix(base, filepath.Ext(base))
@@ -110,28 +162,112 @@ func loadOneJobINI(path string) (*Job) {
 	}
 
 
-	if b == false {
-		logger.Infof("Job <%s> not enabled - skipping", j.Name)
-		return nil
+	// templated job: one job per host list row
+	if row != nil {
+		j.Name = row[hostColumn]
+		j.HostVars = row
 	}
 
-	j.Enabled=  b
+	// disabled job files are skipped by loadOneJobINI unless requested
+	j.Enabled = true
 
-	if err := iniCfg.Section("ca").MapTo(&j.Ca); err != nil {
-		logger.Errorf("%q: map [ca]: %v", path, err)
-		return nil
+	// CA profile and [target] defaults first, the job file overrides single keys
+	if profile := iniCfg.Section("ca").Key("ca_profile").String(); profile != "" {
+		if err := global.applyCAProfile(profile, &j.Ca); err != nil {
+			return nil, []ConfigProblem{j.Problemf("ca", "ca_profile", "%v", err)}
+		}
 	}
-	if err := iniCfg.Section("target").MapTo(&j.Target); err != nil {
-		logger.Errorf("%q: map [target]: %v", path, err)
-		return nil
+	if err := global.applyDefaults(&j.Target); err != nil {
+		return nil, []ConfigProblem{newProblem(global.path, j.Name, globalDefaultsSection, "", err.Error())}
+	}
+
+	for _, m := range []struct {
+		section string
+		dst     any
+	}{{"ca", &j.Ca}, {"target", &j.Target}, {"verify", &j.Verify}} {
+		if err := iniCfg.Section(m.section).MapTo(m.dst); err != nil {
+			return nil, []ConfigProblem{j.Problemf(m.section, "", "map [%s]: %v", m.section, err)}
+		}
+	}
+
+	// TOFU host keys are recorded next to the jobs.d folder unless configured otherwise
+	if j.Target.TOFUStateFile == "" {
+		j.Target.TOFUStateFile = filepath.Join(filepath.Dir(filepath.Dir(path)), "known_hosts.tofu")
+	}
+	// CA chains fetched from EJBCA are cached next to the jobs.d folder unless configured otherwise
+	if j.Ca.CAChainCache == "" {
+		j.Ca.CAChainCache = filepath.Join(filepath.Dir(filepath.Dir(path)), "ca-chain.d", j.Name + ".pem")
+	}
+
+	var problems []ConfigProblem
+
+	// the password may be a reference (env:, file:, exec:) instead of the plaintext value
+	password, err := secrets.resolve(j.Ca.Password)
+	if err != nil {
+		problems = append(problems, j.Problemf("ca", "password", "%v", err))
+	}
+	j.Ca.Password = password
+	j.RegisterSecrets()
+
+	// host list columns may be used in the SANs, e.g. IP:${host_ip}
+	j.Target.SubjectAltName, err = expandHostVars(j.Target.SubjectAltName, &j)
+	if err != nil {
+		problems = append(problems, j.Problemf("target", "subjectAltName", "%v", err))
+	} else {
+		// structured SANs are used for local CSRs and the CSR policy check
+		san, err := ParseSubjectAltName(j.Target.SubjectAltName)
+		if err != nil {
+			problems = append(problems, j.Problemf("target", "subjectAltName", "%v", err))
+		}
+		j.Target.SANs = san
+	}
+
+	// addresses of the service probes, e.g. tls_address = ${job_host}:443 in a templated job
+	for _, a := range []struct {
+		section, key string
+		value        *string
+	}{
+		{"target", "probe_address", &j.Target.ProbeAddress},
+		{"target", "probe_sni", &j.Target.ProbeSNI},
+		{"verify", "tls_address", &j.Verify.TLSAddress},
+		{"verify", "sni", &j.Verify.SNI},
+	} {
+		if *a.value, err = expandHostVars(*a.value, &j); err != nil {
+			problems = append(problems, j.Problemf(a.section, a.key, "%v", err))
+		}
+	}
+
+	// tags select jobs on the command line, host list columns may be used, e.g. ${host_location}
+	tags, err := expandHostVars(secJob.Key("tags").String(), &j)
+	if err != nil {
+		problems = append(problems, j.Problemf("job", "tags", "%v", err))
+	}
+	for _, t := range trimSlice(strings.Split(tags, ",")) {
+		j.Tags = append(j.Tags, strings.ToLower(t))
+	}
+
+	reason, err := ParseRevocationReason(j.Ca.RevocationReasonRaw)
+	if err != nil {
+		problems = append(problems, j.Problemf("ca", "revocation_reason", "%v", err))
+	}
+	j.Ca.RevocationReason = reason
+
+	// EJBCA needs all three to (re)configure an End Entity
+	if j.ManageEndEntity() && (j.Ca.CertProfile == "" || j.Ca.EEProfile == "" || j.Ca.CAName == "") {
+		problems = append(problems, j.Problemf("ca", "", "cert_profile, ee_profile and ca_name must be set together"))
+	}
+	if j.Ca.AutoCreateEndEntity && !j.ManageEndEntity() {
+		problems = append(problems, j.Problemf("ca", "auto_create_end_entity", "requires cert_profile, ee_profile and ca_name"))
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
 	}
 
-	// trim the list becasue "a, b" would contain " b"
-	//j.Target.SubjectAltName = trimSlice(j.Target.SubjectAltName)
 	j.Finalize() 
 
 
-	return &j
+	return &j, nil
 }
 
 
@@ -166,6 +302,11 @@ func (j *Job) Finalize() {
     sec := ParseEJBCAValidity(j.Target.ChangeAfterRaw)
     j.Target.ChangeAfter = sec
 
+    j.Verify.Wait = ParseEJBCAValidity(j.Verify.WaitRaw)
+    if j.Verify.Attempts < 1 {
+    	j.Verify.Attempts = 1
+    }
+
 
     if fileExists(j.Ca.CACert) {
 	    data, err := os.ReadFile(j.Ca.CACert)
@@ -175,9 +316,10 @@ func (j *Job) Finalize() {
 			logger.Infof("Finalize Load CA Certificate, job: %s: %s\n",j.Name, j.Ca.CACert)
 
 			j.Ca.CACertLoaded = string(data)
+			j.Ca.CAChain = j.Ca.CACertLoaded
 		}
 	
-	} else {
+	} else if j.Ca.CACert != "" || !j.FetchCAChain() {
 		logger.Warnf("Finalize Load CA Certificate, job: %s, not found %s - SKIPPING !\n",j.Name, j.Ca.CACert)
 	}
  
@@ -186,7 +328,8 @@ func (j *Job) Finalize() {
 
 /**
  *  GetShellVariables renders job, CA, and target values into shell-compatible
- *  environment variable assignments.
+ *  environment variable assignments. Values are POSIX single-quoted, so quotes,
+ *  "$", backticks or newlines in a value are taken literally by the shell on the target.
  *
  *  Params:
  *    - j: job providing source values.
@@ -200,29 +343,69 @@ func (t *Target) GetShellVariables(j *Job) (string) {
 	result := ""
     
     for _, envVar := range t.CommandEnvList { 
+
+    	// reference could not be parsed into <section>_<key>, already reported by extractVars
+    	if envVar.ShellVariable == "" {
+    		continue
+    	}
    	
-    	var value reflect.Value
+    	var src any
 
     	switch envVar.IniSection {
 			case "job":
-				value = FieldByIniTag(j, envVar.IniVariable)
+				src = j
 			case "ca":
-				value = FieldByIniTag(j.Ca, envVar.IniVariable)
+				src = j.Ca
+			case "verify":
+				src = j.Verify
 			default:
-				value = FieldByIniTag(j.Target, envVar.IniVariable)
+				src = j.Target
 		} 	
 
-		line := envVar.IniSection + "_" + envVar.IniVariable + "=\"" + value.String() + "\""
+		str := ""
+		if envVar.IniSection == "host" {
+			v, ok := j.HostVars[strings.ToLower(envVar.IniVariable)]
+			if !ok {
+				logger.Warnf("job <%s>: ${%s} is no column of the host list - set to empty\n", j.Name, envVar.ShellVariable)
+			}
+			str = v
+		} else if scriptAllowed(src, envVar.IniVariable) {
+			str = valueString(FieldByIniTag(src, envVar.IniVariable))
+		} else {
+			logger.Warnf("job <%s>: ${%s} is a secret and not available to scripts - set to empty\n", j.Name, envVar.ShellVariable)
+		}
+
+		line := envVar.IniSection + "_" + envVar.IniVariable + "=" + ShellQuote(str)
 		result += line + "\n"
 
 	}
     return result
 }
 
+/**
+ *  valueString converts a struct field value into its string form for the shell.
+ *
+ *  Params:
+ *    - v: field value, may be invalid if the field was not found.
+ *
+ *  Returns:
+ *    - string: string representation, empty for invalid values.
+ *
+ */
+func valueString(v reflect.Value) (string) {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
 
 /**
  *  ParseEJBCAValidity parses an EJBCA-style validity string into seconds.
- *  Supported units: y, mo, d, h, m, s.
+ *  Supported units: y, mo, d, h, m, s. Invalid strings are logged and result in 0.
  *
  *  Params:
  *    - s: validity string (e.g. "1y 2mo 4d 1h").
@@ -232,9 +415,30 @@ func (t *Target) GetShellVariables(j *Job) (string) {
  *
  */
 func ParseEJBCAValidity(s string) (uint64) {
+	total, err := parseValidity(s)
+	if err != nil {
+		logger.Errorln(err)
+		return 0
+	}
+	return total
+}
+
+
+/**
+ *  parseValidity parses an EJBCA-style validity string into seconds, see ParseEJBCAValidity.
+ *
+ *  Params:
+ *    - s: validity string (e.g. "1y 2mo 4d 1h").
+ *
+ *  Returns:
+ *    - uint64: total duration in seconds, 0 for an empty string.
+ *    - error: non-nil if the string cannot be parsed.
+ *
+ */
+func parseValidity(s string) (uint64, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
-		return 0
+		return 0, nil
 	}
 	
 	const (
@@ -262,13 +466,11 @@ func ParseEJBCAValidity(s string) (uint64) {
 			i++
 		}
 		if startNum == i {
-			logger.Errorf("expected number at %q", s[startNum:])
-			return 0
+			return 0, fmt.Errorf("expected number at %q", s[startNum:])
 		}
 		n64, err := strconv.ParseUint(s[startNum:i], 10, 64)
 		if err != nil {
-			logger.Errorf("invalid number %q: %v", s[startNum:i], err)
-			return 0
+			return 0, fmt.Errorf("invalid number %q: %v", s[startNum:i], err)
 		}
 
 		// whitespace skip
@@ -276,8 +478,7 @@ func ParseEJBCAValidity(s string) (uint64) {
 			i++
 		}
 		if i >= len(s) {
-			logger.Errorf("missing unit after %d", n64)
-			return 0
+			return 0, fmt.Errorf("missing unit after %d", n64)
 		}
 
 		// unit read (important: "mo" comes in front of "m")
@@ -302,29 +503,38 @@ func ParseEJBCAValidity(s string) (uint64) {
 			mul = 1
 			i += 1
 		default:
-			logger.Errorf("unknown unit at %q (allowed: y, mo, d, h, m, s)", s[i:])
-			return 0
+			return 0, fmt.Errorf("unknown unit at %q (allowed: y, mo, d, h, m, s)", s[i:])
 		}
 
 		// overflow-add securely
 		add := n64 * mul
 		if mul != 0 && add/mul != n64 {
-			logger.Errorf("overflow computing %d * %d", n64, mul)
-			return 0
+			return 0, fmt.Errorf("overflow computing %d * %d", n64, mul)
 		}
 		if total > ^uint64(0)-add {
-			logger.Errorf("overflow adding %d", add)
-			return 0
+			return 0, fmt.Errorf("overflow adding %d", add)
 		}
 		total += add
 	}
 
-	return total
+	return total, nil
 }
 
 
 /**
- *  FieldByIniTag finds a struct field by its ini tag using reflection.
+ *  varName returns the name of a field in the script variables: its ini tag, or its
+ *  `var` tag for loaded or derived values which are not read from the INI file.
+ *
+ */
+func varName(field reflect.StructField) (string) {
+	if name := field.Tag.Get("var"); name != "" {
+		return name
+	}
+	return field.Tag.Get("ini")
+}
+
+/**
+ *  FieldByIniTag finds a struct field by its ini tag (or var tag, see varName) using reflection.
  *
  *  Params:
  *    - v: struct or pointer to struct to inspect.
@@ -345,7 +555,7 @@ func FieldByIniTag(v any, iniName string) (reflect.Value) {
 
 	for i := 0; i < rt.NumField(); i++ {
 		field := rt.Field(i)
-		tag := field.Tag.Get("ini")
+		tag := varName(field)
 
 		if tag == iniName {
 			return rv.Field(i)
@@ -355,6 +565,32 @@ func FieldByIniTag(v any, iniName string) (reflect.Value) {
 	return reflect.Value{}
 }
 
+/**
+ *  scriptAllowed reports whether a field may be exported to scripts: fields tagged
+ *  `secret:"true"` are only exported if they are also tagged `script:"true"`.
+ *
+ *  Params:
+ *    - v: struct or pointer to struct to inspect.
+ *    - iniName: INI tag name of the field.
+ *
+ *  Returns:
+ *    - bool: false for secret fields, true otherwise (also if the field does not exist).
+ *
+ */
+func scriptAllowed(v any, iniName string) (bool) {
+	rt := reflect.TypeOf(v)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if varName(field) == iniName {
+			return field.Tag.Get("secret") != "true" || field.Tag.Get("script") == "true"
+		}
+	}
+	return true
+}
+
 /**
  *  fileExists reports whether a file exists at the given path.
  *
@@ -0,0 +1,379 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config reads the host list of a templated job from an inventory
+ *  ([job] inventory): an Ansible INI/YAML inventory, a CSV file or a JSON document
+ *  (local file or fetched via HTTPS). Each inventory host becomes one job, the job
+ *  file is the template and the host variables are available as ${host_<variable>}.
+ *
+ */
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+// built-in values of [job] inventory_type
+const (
+	InventoryTypeAnsible = "ansible"
+	InventoryTypeCSV     = "csv"
+	InventoryTypeJSON    = "json"
+)
+
+const (
+	inventoryHTTPTimeout = 30 * time.Second
+	inventoryMaxSize     = 10 << 20 // maximum size of a fetched inventory document
+)
+
+
+/**
+ *  InventoryOptions are the [job] inventory_* settings passed to an inventory provider.
+ *
+ */
+type InventoryOptions struct {
+	Group  string // inventory_group: only hosts of this group (and its child groups)
+	Token  string // inventory_token: resolved bearer token for HTTPS sources
+	CAFile string // inventory_ca: CA file to verify an HTTPS source, system roots if empty
+}
+
+
+/**
+ *  InventoryProvider reads the hosts of an inventory.
+ *
+ *  Params:
+ *    - src: inventory file (absolute path) or URL.
+ *    - opt: inventory options of the job.
+ *
+ *  Returns:
+ *    - []map[string]string: one map variable -> value per host, the host name in "host".
+ *    - error: non-nil if the inventory cannot be read.
+ *
+ */
+type InventoryProvider func(src string, opt InventoryOptions) ([]map[string]string, error)
+
+
+var (
+	inventoryMu        sync.RWMutex
+	inventoryProviders = map[string]InventoryProvider{
+		InventoryTypeAnsible: ansibleInventory,
+		InventoryTypeCSV:     csvInventory,
+		InventoryTypeJSON:    jsonInventory,
+	}
+)
+
+
+/**
+ *  RegisterInventoryProvider adds an inventory provider which is used by job files
+ *  with "inventory_type = <name>". A provider registered with the name of a built-in
+ *  provider replaces it.
+ *
+ *  Params:
+ *    - name: value of [job] inventory_type (case-insensitive).
+ *    - p: provider.
+ *
+ */
+func RegisterInventoryProvider(name string, p InventoryProvider) {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inventoryProviders[strings.ToLower(name)] = p
+}
+
+
+/**
+ *  inventoryProvider returns the provider registered for an inventory type.
+ *
+ */
+func inventoryProvider(name string) (InventoryProvider, bool) {
+	inventoryMu.RLock()
+	defer inventoryMu.RUnlock()
+	p, ok := inventoryProviders[name]
+	return p, ok
+}
+
+
+/**
+ *  inventoryType guesses the inventory type from the source if inventory_type is not set:
+ *  URLs are JSON documents, files are detected by their extension, Ansible otherwise.
+ *
+ */
+func inventoryType(src string) string {
+	if isURL(src) {
+		return InventoryTypeJSON
+	}
+	switch strings.ToLower(filepath.Ext(src)) {
+		case ".csv":
+			return InventoryTypeCSV
+		case ".json":
+			return InventoryTypeJSON
+	}
+	return InventoryTypeAnsible
+}
+
+
+/**
+ *  isURL reports whether an inventory source is a URL rather than a file.
+ *
+ */
+func isURL(src string) bool {
+	return strings.Contains(src, "://")
+}
+
+
+/**
+ *  loadInventory reads the host list of a job file from the inventory configured in [job].
+ *
+ *  Params:
+ *    - sec: [job] section of the job file.
+ *    - path: job file, used to resolve relative file names.
+ *    - src: value of [job] inventory.
+ *
+ *  Returns:
+ *    - []map[string]string: one map variable -> value per host, sorted by host.
+ *    - error: non-nil if the inventory cannot be read or has invalid entries.
+ *
+ */
+func loadInventory(sec *ini.Section, path, src string) ([]map[string]string, error) {
+
+	typ := strings.ToLower(strings.TrimSpace(sec.Key("inventory_type").String()))
+	if typ == "" {
+		typ = inventoryType(src)
+	}
+	provider, ok := inventoryProvider(typ)
+	if !ok {
+		return nil, fmt.Errorf("[job] inventory_type: unknown inventory type %q", typ)
+	}
+
+	opt := InventoryOptions{
+		Group:  strings.TrimSpace(sec.Key("inventory_group").String()),
+		CAFile: strings.TrimSpace(sec.Key("inventory_ca").String()),
+	}
+	if opt.CAFile != "" {
+		opt.CAFile = relativeTo(path, opt.CAFile)
+	}
+	if raw := strings.TrimSpace(sec.Key("inventory_token").String()); raw != "" {
+		token, err := ResolveSecret(raw)
+		if err != nil {
+			return nil, fmt.Errorf("[job] inventory_token: %w", err)
+		}
+		logger.AddSecret(token)
+		defer logger.RemoveSecret(token)
+		opt.Token = token
+	}
+
+	if !isURL(src) {
+		src = relativeTo(path, src)
+	}
+
+	logger.Debugf("%q: reading %s inventory %s\n", path, typ, src)
+	rows, err := provider(src, opt)
+	if err != nil {
+		return nil, fmt.Errorf("[job] inventory %s: %w", src, err)
+	}
+	rows, err = checkHostRows(rows, src)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(rows, func(a, b int) bool { return rows[a][hostColumn] < rows[b][hostColumn] })
+	return rows, nil
+}
+
+
+/**
+ *  hostVarsRow builds a host list row from the variables of an inventory host.
+ *  Variables whose name cannot be used as ${host_<variable>} and non-scalar values
+ *  are skipped.
+ *
+ *  Params:
+ *    - host: inventory host name, stored in "host" (overrides a "host" variable).
+ *    - vars: variables of the host.
+ *
+ *  Returns:
+ *    - map[string]string: row for checkHostRows.
+ *
+ */
+func hostVarsRow(host string, vars map[string]any) map[string]string {
+	row := map[string]string{}
+	for k, v := range vars {
+		name := strings.ToLower(strings.TrimSpace(k))
+		if !reHostColumn.MatchString(name) {
+			logger.Debugf("inventory host %s: variable %q skipped, not usable as ${host_<variable>}\n", host, k)
+			continue
+		}
+		switch val := v.(type) {
+			case nil:
+				row[name] = ""
+			case string:
+				row[name] = val
+			case bool, int, int64, uint64, float64, json.Number:
+				row[name] = fmt.Sprint(val)
+			default:
+				logger.Debugf("inventory host %s: variable %q skipped, not a scalar value\n", host, k)
+		}
+	}
+	row[hostColumn] = host
+	return row
+}
+
+
+/**
+ *  readInventorySource reads a local inventory file or fetches an HTTPS URL.
+ *  Plain HTTP is refused, the inventory decides which certificates are requested.
+ *
+ *  Params:
+ *    - src: file or https:// URL.
+ *    - opt: inventory options providing the CA file and bearer token.
+ *
+ *  Returns:
+ *    - []byte: document.
+ *    - error: non-nil if the document cannot be read.
+ *
+ */
+func readInventorySource(src string, opt InventoryOptions) ([]byte, error) {
+
+	if !isURL(src) {
+		return os.ReadFile(src)
+	}
+	if !strings.HasPrefix(strings.ToLower(src), "https://") {
+		return nil, fmt.Errorf("only https:// URLs are supported")
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opt.CAFile != "" {
+		caPem, err := os.ReadFile(opt.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read inventory_ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("inventory_ca %s: no certificates found", opt.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsCfg},
+		Timeout:   inventoryHTTPTimeout,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if opt.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opt.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, inventoryMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > inventoryMaxSize {
+		return nil, fmt.Errorf("document larger than %d bytes", inventoryMaxSize)
+	}
+	return b, nil
+}
+
+
+/**
+ *  csvInventory reads a CSV file with a header line and a "host" column,
+ *  the same format as [job] hosts_file.
+ *
+ */
+func csvInventory(src string, opt InventoryOptions) ([]map[string]string, error) {
+	if opt.Group != "" {
+		return nil, fmt.Errorf("inventory_group is not supported for CSV inventories")
+	}
+	b, err := readInventorySource(src, opt)
+	if err != nil {
+		return nil, err
+	}
+	return parseHostCSV(string(b), src)
+}
+
+
+/**
+ *  jsonInventory reads a JSON document which is either an array of host objects
+ *  or an object with such an array in "hosts":
+ *
+ *    [{"host": "cam1.domain.tld", "ip": "10.1.2.1"}, ...]
+ *    {"hosts": [{"host": "cam1.domain.tld", "ip": "10.1.2.1"}, ...]}
+ *
+ *  Every host object needs a "host" member, the other scalar members are the host variables.
+ *
+ */
+func jsonInventory(src string, opt InventoryOptions) ([]map[string]string, error) {
+
+	if opt.Group != "" {
+		return nil, fmt.Errorf("inventory_group is not supported for JSON inventories")
+	}
+	b, err := readInventorySource(src, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Hosts []map[string]any `json:"hosts"`
+	}
+	var hosts []map[string]any
+	if trimmed := strings.TrimSpace(string(b)); strings.HasPrefix(trimmed, "[") {
+		err = unmarshalJSON(b, &hosts)
+	} else {
+		err = unmarshalJSON(b, &doc)
+		hosts = doc.Hosts
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse JSON: %w", err)
+	}
+
+	rows := make([]map[string]string, 0, len(hosts))
+	for i, h := range hosts {
+		name, _ := h[hostColumn].(string)
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("entry %d: no %q member", i+1, hostColumn)
+		}
+		rows = append(rows, hostVarsRow(strings.TrimSpace(name), h))
+	}
+	return rows, nil
+}
+
+
+/**
+ *  unmarshalJSON decodes a JSON document keeping numbers in their original notation.
+ *
+ */
+func unmarshalJSON(b []byte, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
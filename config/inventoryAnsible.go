@@ -0,0 +1,592 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config reads Ansible inventories (INI or YAML format) including
+ *  group and host variables, also from group_vars/ and host_vars/ next to
+ *  the inventory file.
+ *
+ */
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+// implicit Ansible groups
+const (
+	ansibleGroupAll       = "all"
+	ansibleGroupUngrouped = "ungrouped"
+)
+
+// host range like cam[01:10].domain.tld or rack-[a:f]
+var reAnsibleRange = regexp.MustCompile(`\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?\]`)
+
+
+/**
+ *  ansibleGroup is one group of an Ansible inventory.
+ *
+ */
+type ansibleGroup struct {
+	hosts    map[string]map[string]any // hosts listed in the group with their inline variables
+	vars     map[string]any
+	children []string
+}
+
+/**
+ *  ansibleGroups are the groups of an Ansible inventory by name.
+ *
+ */
+type ansibleGroups map[string]*ansibleGroup
+
+
+/**
+ *  group returns the group with the given name, it is created if it does not exist.
+ *
+ */
+func (g ansibleGroups) group(name string) *ansibleGroup {
+	grp, ok := g[name]
+	if !ok {
+		grp = &ansibleGroup{hosts: map[string]map[string]any{}, vars: map[string]any{}}
+		g[name] = grp
+	}
+	return grp
+}
+
+
+/**
+ *  addHost adds a host with its inline variables to a group.
+ *
+ */
+func (g ansibleGroups) addHost(group, host string, vars map[string]any) {
+	grp := g.group(group)
+	if grp.hosts[host] == nil {
+		grp.hosts[host] = map[string]any{}
+	}
+	for k, v := range vars {
+		grp.hosts[host][k] = v
+	}
+}
+
+
+/**
+ *  ansibleInventory reads an Ansible inventory file in INI or YAML (*.yml, *.yaml) format.
+ *  The variables of a host are merged in Ansible's order: "all", parent groups before
+ *  child groups, then the host variables; group_vars/<group>.yml and host_vars/<host>.yml
+ *  next to the inventory override the variables of the inventory file.
+ *
+ */
+func ansibleInventory(src string, opt InventoryOptions) ([]map[string]string, error) {
+
+	if isURL(src) {
+		return nil, fmt.Errorf("Ansible inventories must be local files")
+	}
+
+	var (
+		groups ansibleGroups
+		err    error
+	)
+	switch strings.ToLower(filepath.Ext(src)) {
+		case ".yml", ".yaml":
+			groups, err = parseAnsibleYAML(src)
+		default:
+			groups, err = parseAnsibleINI(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	selected := ansibleGroupAll
+	if opt.Group != "" {
+		selected = opt.Group
+		if _, ok := groups[selected]; !ok && selected != ansibleGroupAll {
+			return nil, fmt.Errorf("group %q not found", selected)
+		}
+	}
+
+	dir := filepath.Dir(src)
+	parents := groups.parents()
+	depth := groups.depths(parents)
+
+	var rows []map[string]string
+	for _, host := range groups.hostsOf(selected) {
+		vars, err := groups.hostVars(host, dir, parents, depth)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, hostVarsRow(host, vars))
+	}
+	return rows, nil
+}
+
+
+/**
+ *  parents returns the parent groups of every group, "all" is the parent of every top level group.
+ *
+ */
+func (g ansibleGroups) parents() map[string][]string {
+	parents := map[string][]string{}
+	for name, grp := range g {
+		for _, child := range grp.children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+	for name := range g {
+		if name != ansibleGroupAll && len(parents[name]) == 0 {
+			parents[name] = []string{ansibleGroupAll}
+		}
+	}
+	return parents
+}
+
+
+/**
+ *  depths returns the distance of every group from "all", used to apply
+ *  the variables of parent groups before those of their children.
+ *
+ */
+func (g ansibleGroups) depths(parents map[string][]string) map[string]int {
+	depth := map[string]int{ansibleGroupAll: 0}
+	var walk func(name string, seen map[string]bool) int
+	walk = func(name string, seen map[string]bool) int {
+		if d, ok := depth[name]; ok {
+			return d
+		}
+		if seen[name] {
+			return 0 // cyclic children, Ansible refuses those as well
+		}
+		seen[name] = true
+		d := 0
+		for _, p := range parents[name] {
+			if pd := walk(p, seen) + 1; pd > d {
+				d = pd
+			}
+		}
+		depth[name] = d
+		return d
+	}
+	for name := range g {
+		walk(name, map[string]bool{})
+	}
+	return depth
+}
+
+
+/**
+ *  hostsOf returns the sorted hosts of a group and all of its child groups.
+ *
+ */
+func (g ansibleGroups) hostsOf(group string) []string {
+	hosts := map[string]bool{}
+	seen := map[string]bool{}
+	var walk func(name string)
+	walk = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		grp, ok := g[name]
+		if !ok {
+			return
+		}
+		for h := range grp.hosts {
+			hosts[h] = true
+		}
+		for _, c := range grp.children {
+			walk(c)
+		}
+	}
+
+	if group == ansibleGroupAll {
+		for name := range g {
+			walk(name)
+		}
+	} else {
+		walk(group)
+	}
+
+	out := make([]string, 0, len(hosts))
+	for h := range hosts {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+
+/**
+ *  hostVars merges the variables of a host from all of its groups, the inline
+ *  host variables and the group_vars/ and host_vars/ files.
+ *
+ */
+func (g ansibleGroups) hostVars(host, dir string, parents map[string][]string, depth map[string]int) (map[string]any, error) {
+
+	// groups of the host incl. their ancestors
+	member := map[string]bool{ansibleGroupAll: true}
+	var up func(name string)
+	up = func(name string) {
+		if member[name] {
+			return
+		}
+		member[name] = true
+		for _, p := range parents[name] {
+			up(p)
+		}
+	}
+	for name, grp := range g {
+		if _, ok := grp.hosts[host]; ok {
+			up(name)
+		}
+	}
+
+	names := make([]string, 0, len(member))
+	for name := range member {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if depth[names[a]] != depth[names[b]] {
+			return depth[names[a]] < depth[names[b]]
+		}
+		return names[a] < names[b]
+	})
+
+	vars := map[string]any{}
+	merge := func(m map[string]any) {
+		for k, v := range m {
+			vars[k] = v
+		}
+	}
+	for _, name := range names {
+		if grp, ok := g[name]; ok {
+			merge(grp.vars)
+		}
+		fileVars, err := readAnsibleVarsFile(filepath.Join(dir, "group_vars"), name)
+		if err != nil {
+			return nil, err
+		}
+		merge(fileVars)
+	}
+	for _, name := range names {
+		if grp, ok := g[name]; ok {
+			merge(grp.hosts[host])
+		}
+	}
+	fileVars, err := readAnsibleVarsFile(filepath.Join(dir, "host_vars"), host)
+	if err != nil {
+		return nil, err
+	}
+	merge(fileVars)
+
+	return vars, nil
+}
+
+
+/**
+ *  readAnsibleVarsFile reads <dir>/<name>.yml, <name>.yaml or <name> if one of them exists.
+ *  Vault encrypted files are skipped with a warning.
+ *
+ */
+func readAnsibleVarsFile(dir, name string) (map[string]any, error) {
+	for _, f := range []string{name + ".yml", name + ".yaml", name} {
+		path := filepath.Join(dir, f)
+		b, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(string(b), "$ANSIBLE_VAULT") {
+			logger.Warnf("%s is vault encrypted - skipped\n", path)
+			return nil, nil
+		}
+		vars := map[string]any{}
+		if err := yaml.Unmarshal(b, &vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return vars, nil
+	}
+	return nil, nil
+}
+
+
+/**
+ *  parseAnsibleINI parses an Ansible inventory in INI format:
+ *
+ *    cam0.domain.tld
+ *    [cams]
+ *    cam[1:3].domain.tld location=building-a
+ *    [cams:vars]
+ *    ssh_port=2222
+ *    [site:children]
+ *    cams
+ *
+ */
+func parseAnsibleINI(src string) (ansibleGroups, error) {
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := ansibleGroups{}
+	groups.group(ansibleGroupAll)
+
+	group, kind := ansibleGroupUngrouped, "hosts"
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group, kind = line[1:len(line)-1], "hosts"
+			if i := strings.LastIndex(group, ":"); i >= 0 {
+				group, kind = group[:i], group[i+1:]
+			}
+			if kind != "hosts" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("%s:%d: unknown section type %q", src, lineNo, kind)
+			}
+			groups.group(group)
+			continue
+		}
+
+		switch kind {
+			case "vars":
+				k, v, ok := strings.Cut(line, "=")
+				if !ok {
+					return nil, fmt.Errorf("%s:%d: expected key=value", src, lineNo)
+				}
+				groups.group(group).vars[strings.TrimSpace(k)] = unquoteAnsible(strings.TrimSpace(v))
+			case "children":
+				grp := groups.group(group)
+				grp.children = append(grp.children, line)
+				groups.group(line)
+			default:
+				fields := splitAnsibleFields(line)
+				vars := map[string]any{}
+				for _, field := range fields[1:] {
+					k, v, ok := strings.Cut(field, "=")
+					if !ok {
+						return nil, fmt.Errorf("%s:%d: expected key=value, got %q", src, lineNo, field)
+					}
+					vars[k] = unquoteAnsible(v)
+				}
+				hosts, err := expandAnsibleRange(fields[0])
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", src, lineNo, err)
+				}
+				for _, h := range hosts {
+					groups.addHost(group, h, vars)
+				}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+
+/**
+ *  splitAnsibleFields splits a host line at white space outside of quotes.
+ *
+ */
+func splitAnsibleFields(line string) []string {
+	var (
+		fields []string
+		cur    strings.Builder
+		quote  rune
+	)
+	for _, r := range line {
+		switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+				cur.WriteRune(r)
+			case r == '"' || r == '\'':
+				quote = r
+				cur.WriteRune(r)
+			case r == ' ' || r == '\t':
+				if cur.Len() > 0 {
+					fields = append(fields, cur.String())
+					cur.Reset()
+				}
+			default:
+				cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+
+/**
+ *  unquoteAnsible removes surrounding single or double quotes from an INI value.
+ *
+ */
+func unquoteAnsible(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+
+/**
+ *  expandAnsibleRange expands a host pattern with a numeric ([01:10], [1:10:2])
+ *  or alphabetic ([a:f]) range into the host names.
+ *
+ */
+func expandAnsibleRange(pattern string) ([]string, error) {
+
+	m := reAnsibleRange.FindStringSubmatchIndex(pattern)
+	if m == nil {
+		return []string{pattern}, nil
+	}
+	prefix, suffix := pattern[:m[0]], pattern[m[1]:]
+	from, to := pattern[m[2]:m[3]], pattern[m[4]:m[5]]
+	step := 1
+	if m[6] >= 0 {
+		step, _ = strconv.Atoi(pattern[m[6]:m[7]])
+		if step < 1 {
+			return nil, fmt.Errorf("invalid range step in %q", pattern)
+		}
+	}
+
+	var names []string
+	if a, errA := strconv.Atoi(from); errA == nil {
+		b, errB := strconv.Atoi(to)
+		if errB != nil || b < a {
+			return nil, fmt.Errorf("invalid host range in %q", pattern)
+		}
+		width := 0
+		if len(from) > 1 && from[0] == '0' {
+			width = len(from)
+		}
+		for i := a; i <= b; i += step {
+			names = append(names, fmt.Sprintf("%0*d", width, i))
+		}
+	} else if len(from) == 1 && len(to) == 1 && from <= to {
+		for c := from[0]; c <= to[0]; c += byte(step) {
+			names = append(names, string(c))
+			if int(c)+step > 255 {
+				break
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("invalid host range in %q", pattern)
+	}
+
+	// the suffix may contain further ranges
+	var hosts []string
+	for _, n := range names {
+		rest, err := expandAnsibleRange(suffix)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rest {
+			hosts = append(hosts, prefix+n+r)
+		}
+	}
+	return hosts, nil
+}
+
+
+/**
+ *  ansibleYAMLGroup is a group of an Ansible inventory in YAML format.
+ *
+ */
+type ansibleYAMLGroup struct {
+	Hosts    map[string]map[string]any    `yaml:"hosts"`
+	Vars     map[string]any               `yaml:"vars"`
+	Children map[string]*ansibleYAMLGroup `yaml:"children"`
+}
+
+
+/**
+ *  parseAnsibleYAML parses an Ansible inventory in YAML format:
+ *
+ *    all:
+ *      hosts:
+ *        cam0.domain.tld:
+ *      children:
+ *        cams:
+ *          hosts:
+ *            cam1.domain.tld:
+ *              location: building-a
+ *          vars:
+ *            ssh_port: 2222
+ *
+ */
+func parseAnsibleYAML(src string) (ansibleGroups, error) {
+
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]*ansibleYAMLGroup
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+
+	groups := ansibleGroups{}
+	groups.group(ansibleGroupAll)
+
+	var add func(name string, y *ansibleYAMLGroup) error
+	add = func(name string, y *ansibleYAMLGroup) error {
+		grp := groups.group(name)
+		if y == nil {
+			return nil
+		}
+		for k, v := range y.Vars {
+			grp.vars[k] = v
+		}
+		for pattern, vars := range y.Hosts {
+			hosts, err := expandAnsibleRange(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: %w", src, err)
+			}
+			for _, h := range hosts {
+				groups.addHost(name, h, vars)
+			}
+		}
+		for child, cy := range y.Children {
+			if name != ansibleGroupAll {
+				grp.children = append(grp.children, child)
+			}
+			if err := add(child, cy); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for name, y := range doc {
+		if err := add(name, y); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
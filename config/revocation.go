@@ -0,0 +1,94 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config maps revocation reason names (RFC 5280 CRLReason) to the
+ *  numeric codes expected by the EJBCA revocation API.
+ *
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RevocationReasonSuperseded is used if no revocation_reason is configured
+const RevocationReasonSuperseded int32 = 4
+
+// RFC 5280 CRLReason codes by name (code 7 is not used)
+var revocationReasons = map[string]int32{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           RevocationReasonSuperseded,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+	"removeFromCRL":        8,
+	"privilegeWithdrawn":   9,
+	"aACompromise":         10,
+}
+
+
+/**
+ *  ParseRevocationReason converts a revocation reason name (case-insensitive)
+ *  or its numeric code into the RFC 5280 reason code.
+ *
+ *  Params:
+ *    - s: reason, e.g. "superseded", "keyCompromise" or "1"; empty selects "superseded".
+ *
+ *  Returns:
+ *    - int32: reason code.
+ *    - error: non-nil if the reason is unknown.
+ *
+ */
+func ParseRevocationReason(s string) (int32, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return RevocationReasonSuperseded, nil
+	}
+
+	if n, err := strconv.Atoi(s); err == nil {
+		for _, code := range revocationReasons {
+			if int(code) == n {
+				return code, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown revocation reason code %d", n)
+	}
+
+	for name, code := range revocationReasons {
+		if strings.EqualFold(name, s) {
+			return code, nil
+		}
+	}
+
+	names := make([]string, 0, len(revocationReasons))
+	for name := range revocationReasons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown revocation reason %q (allowed: %s)", s, strings.Join(names, ", "))
+}
+
+
+/**
+ *  RevocationReasonName returns the RFC 5280 name of a reason code.
+ *
+ */
+func RevocationReasonName(code int32) string {
+	for name, c := range revocationReasons {
+		if c == code {
+			return name
+		}
+	}
+	return strconv.Itoa(int(code))
+}
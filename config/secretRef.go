@@ -0,0 +1,149 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config resolves secret references, so secrets like the End Entity
+ *  password do not have to be stored in plaintext in the job files.
+ *
+ */
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// prefixes of secret references
+const (
+	secretRefEnv  = "env:"
+	secretRefFile = "file:"
+	secretRefExec = "exec:"
+
+	secretExecTimeout = 30 * time.Second
+)
+
+
+/**
+ *  ResolveSecret resolves a secret reference:
+ *    - "env:NAME"          value of the environment variable NAME (must be set),
+ *    - "file:/path"        content of the file, trailing newlines removed,
+ *    - "exec:/cmd args"    STDOUT of the command (run without shell), trailing newlines removed,
+ *  any other value is returned unchanged.
+ *
+ *  Params:
+ *    - raw: configured value.
+ *
+ *  Returns:
+ *    - string: resolved secret.
+ *    - error: non-nil if the reference cannot be resolved or resolves to an empty value.
+ *
+ */
+func ResolveSecret(raw string) (string, error) {
+
+	var (
+		value string
+		err   error
+	)
+
+	switch {
+		case strings.HasPrefix(raw, secretRefEnv):
+			name := strings.TrimSpace(strings.TrimPrefix(raw, secretRefEnv))
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %q not set", name)
+			}
+			value = v
+
+		case strings.HasPrefix(raw, secretRefFile):
+			path := strings.TrimSpace(strings.TrimPrefix(raw, secretRefFile))
+			data, rerr := os.ReadFile(path)
+			if rerr != nil {
+				return "", rerr
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+
+		case strings.HasPrefix(raw, secretRefExec):
+			value, err = execSecret(strings.TrimSpace(strings.TrimPrefix(raw, secretRefExec)))
+			if err != nil {
+				return "", err
+			}
+
+		default:
+			return raw, nil
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("%q resolves to an empty value", raw)
+	}
+	return value, nil
+}
+
+
+/**
+ *  execSecret runs a command (split at whitespace, no shell) and returns its STDOUT.
+ *
+ */
+func execSecret(command string) (string, error) {
+
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("exec: no command given")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("exec %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+
+/**
+ *  secretCache keeps resolved secret references by their raw value, so a reference
+ *  shared by several jobs (e.g. the rows of a templated job file) is resolved once.
+ *
+ */
+type secretCache map[string]cachedSecret
+
+type cachedSecret struct {
+	value string
+	err   error
+}
+
+
+/**
+ *  resolve resolves a secret reference like ResolveSecret, using the cached result
+ *  if the same reference was resolved before.
+ *
+ *  Params:
+ *    - raw: configured value.
+ *
+ *  Returns:
+ *    - string: resolved secret.
+ *    - error: non-nil if the reference cannot be resolved or resolves to an empty value.
+ *
+ */
+func (c secretCache) resolve(raw string) (string, error) {
+	if s, ok := c[raw]; ok {
+		return s.value, s.err
+	}
+	value, err := ResolveSecret(raw)
+	c[raw] = cachedSecret{value: value, err: err}
+	return value, err
+}
@@ -17,11 +17,18 @@ package config
 
 import (
 	"regexp"
+	"strings"
 
 	"github.com/tseiman/embed-cert-manager/logger"
 )
 
 
+var (
+	reExtracatVar     = regexp.MustCompile(`\$\{([^}]+)\}`)
+	reGetPrefixAndVar = regexp.MustCompile(`^([-a-zA-Z0-9]+)_([-a-zA-Z0-9_]+)$`)
+)
+
+
 /**
  *  extractVars scans a shell snippet and extracts referenced variables of the form ${...}.
  *  It expects variables to follow the convention "<section>_<key>" (e.g. target_key_path),
@@ -36,8 +43,6 @@ import (
  *
  */
 func extractVars(s string) []EnvVariable {
-	reExtracatVar := regexp.MustCompile(`\$\{([^}]+)\}`)
-	reGetPrefixAndVar := regexp.MustCompile(`^([-a-zA-Z0-9]+)_([-a-zA-Z0-9_]+)$`)
 	matches := reExtracatVar.FindAllStringSubmatch(s, -1)
 
 	seen := make(map[string]struct{})
@@ -51,7 +56,7 @@ func extractVars(s string) []EnvVariable {
 			if len(prefixAndName) == 3 {
 				vars = append(vars, EnvVariable{ShellVariable : name, IniSection : prefixAndName[1] , IniVariable : prefixAndName[2] })
 			} else {
-				logger.Errorf("can't extract variable becasue it seems to have no prefix %s (e.g. target_subjectAltName)", name, prefixAndName)
+				logger.Errorf("can't extract variable becasue it seems to have no prefix %s (e.g. target_subjectAltName)", name)
 				vars = append(vars, EnvVariable{ShellVariable : ""})
 			}
 
@@ -59,3 +64,19 @@ func extractVars(s string) []EnvVariable {
 	}
 	return vars
 }
+
+
+/**
+ *  ShellQuote quotes a string for POSIX shells using single quotes.
+ *  Embedded single quotes are written as '\'' so the value is taken literally.
+ *
+ *  Params:
+ *    - s: value to quote.
+ *
+ *  Returns:
+ *    - string: quoted value, safe to be used as a single shell word.
+ *
+ */
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
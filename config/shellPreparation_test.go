@@ -0,0 +1,162 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Tests that hostile configuration values reach the target scripts literally:
+ *  every `ini`/`var`-tagged string field is rendered with GetCSRCmd/GetCertSetCmd,
+ *  executed by "sh -c" and must come back byte-for-byte.
+ *
+ */
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// values which would change a script if they were not quoted correctly
+var hostileValues = []struct {
+	name  string
+	value string
+}{
+	{"single quote", `it's`},
+	{"double quote", `say "hi"`},
+	{"command substitution", `$(id)`},
+	{"backticks", "`id`"},
+	{"newline", "line1\nline2\n"},
+	{"backslash", `C:\path\n`},
+	{"trailing space", "value "},
+	{"all", "'\"$(id)`id`\n\\ ; rm -rf / #"},
+	{"quote escape", `'\''`},
+	{"variable", `${HOME}$PATH`},
+}
+
+
+/**
+ *  runScript executes a rendered script with "sh -c" and returns its STDOUT.
+ *
+ */
+func runScript(t *testing.T, script string) string {
+	t.Helper()
+	out, err := exec.Command("sh", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("sh -c failed: %v\nscript:\n%s", err, script)
+	}
+	return string(out)
+}
+
+
+/**
+ *  sections returns the job (sub) structs of the sections and their script variable prefixes.
+ *
+ */
+func sections(j *Job) []struct {
+	prefix string
+	v      reflect.Value
+} {
+	return []struct {
+		prefix string
+		v      reflect.Value
+	}{
+		{"job", reflect.ValueOf(j).Elem()},
+		{"ca", reflect.ValueOf(&j.Ca).Elem()},
+		{"target", reflect.ValueOf(&j.Target).Elem()},
+		{"verify", reflect.ValueOf(&j.Verify).Elem()},
+	}
+}
+
+
+func TestShellVariablesHostileValues(t *testing.T) {
+
+	for _, sec := range sections(&Job{}) {
+		rt := sec.v.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			tag := varName(field)
+			if tag == "" || tag == "-" || field.Type.Kind() != reflect.String {
+				continue
+			}
+
+			for _, hv := range hostileValues {
+				t.Run(sec.prefix+"_"+tag+"/"+hv.name, func(t *testing.T) {
+
+					var j Job
+					j.Name = "host.domain.tld"
+					for _, s := range sections(&j) {
+						if s.prefix == sec.prefix {
+							s.v.Field(i).SetString(hv.value)
+						}
+					}
+
+					want := hv.value
+					if !scriptAllowed(sec.v.Interface(), tag) {
+						want = "" // secrets are not exported to scripts
+					}
+
+					script := `printf '%s' "${` + sec.prefix + "_" + tag + `}"`
+
+					// the script itself is a field: render it through the other command
+					var cmd string
+					if sec.prefix == "target" && tag == "set_cert_command" {
+						j.Target.CSRCommand = script
+						cmd = j.GetCSRCmd()
+					} else {
+						j.Target.SetCertCommand = script
+						cmd = j.GetCertSetCmd()
+					}
+
+					if got := runScript(t, cmd); got != want {
+						t.Errorf("got %q, want %q\nscript:\n%s", got, want, cmd)
+					}
+				})
+			}
+		}
+	}
+}
+
+
+func TestShellVariablesHostColumns(t *testing.T) {
+	for _, hv := range hostileValues {
+		t.Run(hv.name, func(t *testing.T) {
+			j := Job{Name: "host.domain.tld", HostVars: map[string]string{hostColumn: "host.domain.tld", "location": hv.value}}
+			j.Target.CSRCommand = `printf '%s' "${host_location}"`
+			if got := runScript(t, j.GetCSRCmd()); got != hv.value {
+				t.Errorf("got %q, want %q", got, hv.value)
+			}
+		})
+	}
+}
+
+
+func TestShellQuote(t *testing.T) {
+	for _, hv := range hostileValues {
+		t.Run(hv.name, func(t *testing.T) {
+			if got := runScript(t, "printf '%s' "+ShellQuote(hv.value)); got != hv.value {
+				t.Errorf("got %q, want %q", got, hv.value)
+			}
+		})
+	}
+}
+
+
+func TestValueString(t *testing.T) {
+	for _, tc := range []struct {
+		in   reflect.Value
+		want string
+	}{
+		{reflect.Value{}, ""},
+		{reflect.ValueOf("a'b"), "a'b"},
+		{reflect.ValueOf(22), "22"},
+		{reflect.ValueOf(true), "true"},
+	} {
+		if got := valueString(tc.in); got != tc.want {
+			t.Errorf("valueString(%v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package config parses the OpenSSL style subjectAltName value of a job
+ *  (e.g. "DNS:web.domain.tld,IP:10.1.1.1") into typed lists.
+ *
+ */
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+
+/**
+ *  SubjectAltNames holds the parsed subject alternative names of a job.
+ *
+ */
+type SubjectAltNames struct {
+	DNS 			[]string
+	IP 				[]net.IP
+	URI 			[]*url.URL
+	Email 			[]string
+	Other 			[]string 	// RID, dirName and otherName entries, passed to csr_command unchanged
+}
+
+
+/**
+ *  ParseSubjectAltName parses an OpenSSL style SAN list.
+ *  Supported prefixes (case-insensitive): DNS, IP, URI, email. RID, dirName and otherName
+ *  entries are kept as they are in Other, they are not checked.
+ *
+ *  Params:
+ *    - s: comma separated SAN list, e.g. "DNS:web.domain.tld,IP:10.1.1.1".
+ *
+ *  Returns:
+ *    - SubjectAltNames: parsed names.
+ *    - error: non-nil if an entry has no or an unknown prefix or an invalid value.
+ *
+ */
+func ParseSubjectAltName(s string) (SubjectAltNames, error) {
+	var san SubjectAltNames
+
+	for _, entry := range trimSlice(strings.Split(s, ",")) {
+		kind, value, ok := strings.Cut(entry, ":")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return san, fmt.Errorf("SAN entry %q: expected <type>:<value>", entry)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+			case "dns":
+				san.DNS = append(san.DNS, value)
+			case "ip":
+				ip := net.ParseIP(value)
+				if ip == nil {
+					return san, fmt.Errorf("SAN entry %q: invalid IP address", entry)
+				}
+				san.IP = append(san.IP, ip)
+			case "uri":
+				u, err := url.Parse(value)
+				if err != nil || u.Scheme == "" {
+					return san, fmt.Errorf("SAN entry %q: invalid URI", entry)
+				}
+				san.URI = append(san.URI, u)
+			case "email":
+				if !strings.Contains(value, "@") {
+					return san, fmt.Errorf("SAN entry %q: invalid email address", entry)
+				}
+				san.Email = append(san.Email, value)
+			case "rid", "dirname", "othername":
+				san.Other = append(san.Other, entry)
+			default:
+				return san, fmt.Errorf("SAN entry %q: unknown type (allowed: DNS, IP, URI, email, RID, dirName, otherName)", entry)
+		}
+	}
+
+	return san, nil
+}
@@ -31,19 +31,32 @@ type EnvVariable struct {
  *  Ca contains CA/API related configuration for a job.
  *  It defines where the EJBCA SOAP API is located and which TLS material is used to access it.
  *  Fields are populated from the [ca] section in the job INI file via `ini:"..."` tags.
+ *  Fields tagged with `secret:"true"` are masked whenever a script is shown to the user and
+ *  in all log output. They are not available as script variables unless also tagged `script:"true"`.
+ *  Loaded or derived values are not read from the INI file (`ini:"-"`), their `var:"..."` tag
+ *  names the script variable.
  *
  */
 type Ca struct {
+	Profile 		string 			`ini:"ca_profile"`
 	Host 			string 			`ini:"host"`
 	ClientCert 		string 			`ini:"client_cert"`
 	ClientKey 		string 			`ini:"client_key"`
 	ServerCertChain string 			`ini:"server_cert_chain"`
 	CACert			string 			`ini:"ca_cert"`
-	CACertLoaded 	string 			`ini:"ca_cert_loaded"`		
+	CACertLoaded 	string 			`ini:"-" var:"ca_cert_loaded"`
+	CAChainSource 	string 			`ini:"ca_chain_source"`
+	CAChainCache 	string 			`ini:"ca_chain_cache"`
+	CAChain 		string 			`ini:"-" var:"chain"` // CA chain issuer first, root last
 	EJBCAApiUrl     string          `ini:"ejbca_api_url"`
-	Password     	string          `ini:"password"`
-//	CertProfile     string          `ini:"cert_profile"`
-//	CAName 		    string          `ini:"ca_name"`
+	Password     	string          `ini:"password" secret:"true"`
+	RevokeSuperseded bool 			`ini:"revoke_superseded"`
+	RevocationReasonRaw string 		`ini:"revocation_reason"`
+	RevocationReason int32 			`ini:"-"`
+	CertProfile     string          `ini:"cert_profile"`
+	EEProfile 		string 			`ini:"ee_profile"`
+	CAName 		    string          `ini:"ca_name"`
+	AutoCreateEndEntity bool 		`ini:"auto_create_end_entity"`
 //	ResponseType    string          `ini:"response_type"`
 }
 
@@ -59,16 +72,55 @@ type Target struct {
 	SSHUser 		string 			`ini:"ssh_user"`
 	SSHKey 			string 			`ini:"ssh_key"`
 	SSHPort 		int 			`ini:"ssh_port"`
+	HostKeyCheck 	string 			`ini:"host_key_check"`
+	KnownHosts 		string 			`ini:"known_hosts"`
+	HostKeyFingerprint string 		`ini:"host_key_fingerprint"`
+	TOFUStateFile 	string 			`ini:"tofu_state_file"`
 	CertPath 		string    		`ini:"cert_path"`
 	KeyPath 		string    		`ini:"key_path"`
 	CSRPath 		string 			`ini:"csr_path"`
 	SubjectAltName 	string 			`ini:"subjectAltName"` //delim:","
+	SANs 			SubjectAltNames `ini:"-"`
 	ChangeAfterRaw 	string 			`ini:"change_after"`
 	ChangeAfter 	uint64 			`ini:"-"`
 	CSRCommand 		string 			`ini:"csr_command"`
+	CSRPolicy 		string 			`ini:"csr_policy"`
+	KeySource 		string 			`ini:"key_source"`
+	KeyType 		string 			`ini:"key_type"`
+	KeySize 		int 			`ini:"key_size"`
+	KeyCurve 		string 			`ini:"key_curve"`
+	PrivateKey 		string 			`ini:"-" var:"private_key" secret:"true" script:"true"`
 	CommandEnvList 	[]EnvVariable  	`ini:"-"`
 	SetCertCommand 	string 			`ini:"set_cert_command"`
-	Certificate		string 			`ini:"certificate"`
+	Delivery 		string 			`ini:"delivery"`
+	ChainPath 		string 			`ini:"chain_path"`
+	FullChain 		bool 			`ini:"fullchain"`
+	FileOwner 		string 			`ini:"file_owner"`
+	FileGroup 		string 			`ini:"file_group"`
+	FileMode 		string 			`ini:"file_mode"`
+	KeyFileMode 	string 			`ini:"key_file_mode"`
+	Backup 			bool 			`ini:"backup"`
+	RollbackCommand string 			`ini:"rollback_command"`
+	ReloadCommand 	string 			`ini:"reload_command"`
+	Certificate		string 			`ini:"-" var:"certificate"`
+	DeployedCheck 	string 			`ini:"deployed_check"`
+	ProbeAddress 	string 			`ini:"probe_address"`
+	ProbeSNI 		string 			`ini:"probe_sni"`
+}
+
+/**
+ *  Verify contains the optional post-install verification of a job.
+ *  If TLSAddress is set, a TLS handshake against the service is made after the certificate
+ *  was installed to check that the service presents the new certificate.
+ *  Fields are populated from the [verify] section in the job INI file via `ini:"..."` tags.
+ *
+ */
+type Verify struct {
+	TLSAddress 		string 			`ini:"tls_address"`
+	SNI 			string 			`ini:"sni"`
+	WaitRaw 		string 			`ini:"wait"`
+	Wait 			uint64 			`ini:"-"`
+	Attempts 		int 			`ini:"attempts"`
 }
 
 /**
@@ -80,8 +132,12 @@ type Target struct {
 type Job struct {
 	Name 			string			`ini:"host"`
 	Enabled 		bool        	`ini:"enabled"`
+	File 			string 			`ini:"-"` // job file the job was loaded from
+	HostVars 		map[string]string `ini:"-"` // host list row of a templated job, nil otherwise
+	Tags 			[]string 		`ini:"-"` // [job] tags, lower case
 	Ca 				Ca
 	Target 			Target
+	Verify 			Verify
 }
 
 /**
@@ -92,6 +148,8 @@ type Job struct {
 type Config struct {
 	Jobs 			[]Job 
 	ConfPath 		string
+	Problems 		[]ConfigProblem // problems of job files which could not be loaded
+	IncludeDisabled bool 			// also load disabled job files, e.g. to revoke their certificates
 }
 
 
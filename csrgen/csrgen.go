@@ -0,0 +1,143 @@
+package csrgen
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package csrgen generates private keys and PKCS#10 CSRs locally, for targets
+ *  which are not able to run OpenSSL themselves.
+ *
+ */
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/tseiman/embed-cert-manager/config"
+)
+
+const (
+	defaultRSABits = 2048
+	minRSABits     = config.MinRSAKeySize
+	defaultCurve   = config.CurveP256
+)
+
+/**
+ *  GenerateKey creates a new private key.
+ *
+ *  Params:
+ *    - keyType: "rsa", "ecdsa" or "ed25519" (empty selects "rsa").
+ *    - bits: RSA key size, 0 selects 2048.
+ *    - curve: ECDSA curve "P-256", "P-384" or "P-521" (or OpenSSL name), empty selects "P-256".
+ *
+ *  Returns:
+ *    - crypto.Signer: generated private key.
+ *    - error: non-nil on unknown type/curve or too small RSA size.
+ *
+ */
+func GenerateKey(keyType string, bits int, curve string) (crypto.Signer, error) {
+	switch strings.ToLower(strings.TrimSpace(keyType)) {
+	case config.KeyTypeRSA, "":
+		if bits == 0 {
+			bits = defaultRSABits
+		}
+		if bits < minRSABits {
+			return nil, fmt.Errorf("RSA key size %d too small (minimum %d)", bits, minRSABits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+
+	case config.KeyTypeECDSA:
+		c, err := ellipticCurve(curve)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(c, rand.Reader)
+
+	case config.KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+
+	return nil, fmt.Errorf("unknown key type %q (allowed: %s, %s, %s)", keyType, config.KeyTypeRSA, config.KeyTypeECDSA, config.KeyTypeEd25519)
+}
+
+/**
+ *  ellipticCurve maps a curve name (see config.NormalizeCurve) to its implementation.
+ *
+ */
+func ellipticCurve(name string) (elliptic.Curve, error) {
+	curve, err := config.NormalizeCurve(name)
+	if err != nil {
+		return nil, err
+	}
+	switch curve {
+	case "", config.CurveP256:
+		return elliptic.P256(), nil
+	case config.CurveP384:
+		return elliptic.P384(), nil
+	case config.CurveP521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unknown curve %q", name)
+}
+
+/**
+ *  CreateCSR creates a PEM encoded PKCS#10 CSR signed with key.
+ *
+ *  Params:
+ *    - key: private key of the CSR.
+ *    - commonName: subject CN (the job host).
+ *    - san: subject alternative names.
+ *
+ *  Returns:
+ *    - []byte: CSR in PEM format.
+ *    - error: non-nil if the CSR cannot be created.
+ *
+ */
+func CreateCSR(key crypto.Signer, commonName string, san config.SubjectAltNames) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{
+		Subject:        pkix.Name{CommonName: commonName},
+		DNSNames:       san.DNS,
+		IPAddresses:    san.IP,
+		URIs:           san.URI,
+		EmailAddresses: san.Email,
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, fmt.Errorf("create CSR: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+/**
+ *  KeyToPEM encodes a private key as PKCS#8 PEM ("PRIVATE KEY").
+ *
+ *  Params:
+ *    - key: private key to encode.
+ *
+ *  Returns:
+ *    - []byte: key in PEM format.
+ *    - error: non-nil if the key cannot be marshalled.
+ *
+ */
+func KeyToPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,228 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  This file implements the daemon mode: an internal scheduler which
+ *  re-checks each job when its certificate enters the renewal window
+ *  instead of querying EJBCA on every cron run.
+ *
+ * */
+
+
+import (
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/logger"
+	"github.com/tseiman/embed-cert-manager/state"
+)
+
+
+/**
+ *  schedulerSettings holds the timing limits of the daemon scheduler.
+ * */
+type schedulerSettings struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	Jitter      time.Duration
+}
+
+
+/**
+ *  parseSchedulerSettings converts the daemon CLI flags into scheduler settings.
+ *  Returns:
+ *    - schedulerSettings: parsed settings, MaxInterval is raised to MinInterval if smaller.
+ * */
+func parseSchedulerSettings() schedulerSettings {
+	s := schedulerSettings{
+		MinInterval: time.Duration(config.ParseEJBCAValidity(minIntervalRaw)) * time.Second,
+		MaxInterval: time.Duration(config.ParseEJBCAValidity(maxIntervalRaw)) * time.Second,
+		Jitter:      time.Duration(config.ParseEJBCAValidity(jitterRaw)) * time.Second,
+	}
+	if s.MinInterval <= 0 {
+		logger.Warnf("invalid --min-interval %q, using %s\n", minIntervalRaw, defaultMinInterval)
+		s.MinInterval = time.Duration(config.ParseEJBCAValidity(defaultMinInterval)) * time.Second
+	}
+	if s.MaxInterval < s.MinInterval {
+		s.MaxInterval = s.MinInterval
+	}
+	return s
+}
+
+
+/**
+ *  nextCheck computes when a job has to be checked again.
+ *  The check is planned for the moment the current certificate enters the renewal window
+ *  (NotAfter - change_after), bounded by the minimum/maximum interval plus a random jitter.
+ *  Failed jobs and jobs without known certificate are retried after the minimum interval.
+ *  Params:
+ *    - job: job which was executed.
+ *    - res: result of the job run.
+ *    - now: reference time.
+ *    - s: scheduler settings.
+ *  Returns:
+ *    - time.Time: time of the next check.
+ * */
+func nextCheck(job *config.Job, res jobResult, now time.Time, s schedulerSettings) time.Time {
+
+	next := now.Add(s.MinInterval)
+	if res.Status != statusFailed && res.Status != statusRefused && !res.NotAfter.IsZero() {
+		next = res.NotAfter.Add(-time.Duration(job.Target.ChangeAfter) * time.Second)
+	}
+
+	if min := now.Add(s.MinInterval); next.Before(min) {
+		next = min
+	}
+	if max := now.Add(s.MaxInterval); next.After(max) {
+		next = max
+	}
+	if s.Jitter > 0 {
+		next = next.Add(rand.N(s.Jitter))
+	}
+	return next
+}
+
+
+/**
+ *  seedSchedule plans the first check of every job from its recorded state, so a
+ *  (re)started daemon does not check all jobs against the CA at once. The last attempt
+ *  is taken as reference time; jobs without record are checked immediately.
+ *  Params:
+ *    - jobs: jobs of the daemon.
+ *    - store: state store (may be nil, all jobs are checked immediately then).
+ *    - s: scheduler settings.
+ *  Returns:
+ *    - map[string]time.Time: time of the next check per job name.
+ * */
+func seedSchedule(jobs []config.Job, store *state.Store, s schedulerSettings) map[string]time.Time {
+
+	schedule := map[string]time.Time{}
+	if store == nil {
+		return schedule
+	}
+	for i := range jobs {
+		js, ok := store.Get(jobs[i].Name)
+		if !ok || js.LastAttempt.IsZero() {
+			continue
+		}
+		res := jobResult{Status: js.LastStatus, NotAfter: js.NotAfter}
+		next := nextCheck(&jobs[i], res, js.LastAttempt, s)
+		schedule[jobs[i].Name] = next
+		jobs[i].Log().Infof("next check at %s (from recorded state)\n", next.Format(time.RFC3339))
+	}
+	return schedule
+}
+
+
+/**
+ *  runDaemon runs the scheduler loop until SIGINT/SIGTERM is received.
+ *  Jobs are executed when due; SIGHUP re-reads the job configuration. Jobs which
+ *  are new after a reload are checked immediately, known jobs keep their schedule.
+ * */
+func runDaemon() {
+
+	settings := parseSchedulerSettings()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGINT, syscall.SIGTERM)
+
+	cfg, err := loadConfig()
+	if err != nil { os.Exit(exitConfigError) }
+	if cfg.Jobs, err = selectJobs(cfg.Jobs); err != nil {
+		logger.Errorln(err)
+		os.Exit(exitUsage)
+	}
+
+	logger.Infof("daemon started: min-interval=%s max-interval=%s jitter=%s\n",
+		settings.MinInterval, settings.MaxInterval, settings.Jitter)
+
+	store := openStateStore()
+	schedule := seedSchedule(cfg.Jobs, store, settings)
+
+	// job files which could not be loaded are reported with the next pass
+	pending := configErrorResults(cfg.Problems)
+
+	for {
+		now := time.Now()
+
+		var due []config.Job
+		for _, job := range cfg.Jobs {
+			if t, ok := schedule[job.Name]; !ok || !now.Before(t) {
+				due = append(due, job)
+			}
+		}
+
+		if len(due) > 0 || len(pending) > 0 {
+			results := runJobsParallel(due, parallelJobs, store)
+			for i := range results {
+				next := nextCheck(&due[i], results[i], time.Now(), settings)
+				schedule[due[i].Name] = next
+				due[i].Log().Infof("next check at %s\n", next.Format(time.RFC3339))
+			}
+			finishRun(now, append(results, pending...))
+			pending = nil
+		}
+
+		// sleep until the earliest scheduled job, or max-interval if there is none
+		wait := settings.MaxInterval
+		for _, job := range cfg.Jobs {
+			if d := time.Until(schedule[job.Name]); d < wait {
+				wait = d
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+
+		case <-hup:
+			timer.Stop()
+			logger.Warnln("SIGHUP received - reloading job configuration")
+			newCfg, err := loadConfig()
+			if err != nil {
+				logger.Errorf("reload failed, keeping previous configuration: %v\n", err)
+				continue
+			}
+			if newCfg.Jobs, err = selectJobs(newCfg.Jobs); err != nil {
+				logger.Errorf("reload failed, keeping previous configuration: %v\n", err)
+				continue
+			}
+			known := map[string]time.Time{}
+			for _, job := range newCfg.Jobs {
+				if t, ok := schedule[job.Name]; ok {
+					known[job.Name] = t
+				}
+			}
+			schedule = known
+			// secrets of the previous configuration are no longer masked, shared ones are kept
+			for i := range cfg.Jobs {
+				cfg.Jobs[i].UnregisterSecrets()
+			}
+			for i := range newCfg.Jobs {
+				newCfg.Jobs[i].RegisterSecrets()
+			}
+			cfg = newCfg
+			pending = configErrorResults(cfg.Problems)
+
+		case sig := <-term:
+			timer.Stop()
+			logger.Warnf("%s received - stopping daemon\n", sig)
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file assembles the files for the native certificate delivery
+ *  ([target] delivery = native | sftp | stdin) and hands them to the ssh package.
+ * 
+ * */
+
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ssh"
+)
+
+
+const (
+	deliveryScript     = "script" // certificate is written by set_cert_command
+	defaultFileMode    = "0644"
+	defaultKeyFileMode = "0600"
+)
+
+
+/**
+ *  nativeDelivery reports whether the certificate of a job is written by the tool itself
+ *  instead of by set_cert_command.
+ * */
+func nativeDelivery(job *config.Job) bool {
+	d := strings.ToLower(strings.TrimSpace(job.Target.Delivery))
+	return d != "" && d != deliveryScript
+}
+
+
+/**
+ *  parseFileMode parses an octal file mode like "0640".
+ *  Params:
+ *    - raw: configured mode, def is used if empty.
+ *    - def: default mode.
+ *  Returns:
+ *    - os.FileMode: parsed mode.
+ *    - error: non-nil if raw is not a valid octal mode.
+ * */
+func parseFileMode(raw, def string) (os.FileMode, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		raw = def
+	}
+	m, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("invalid file mode %q", raw)
+	}
+	return os.FileMode(m), nil
+}
+
+
+/**
+ *  deliveryFiles assembles the files written to the target: the certificate
+ *  (with the CA chain appended if fullchain is set), the locally generated key
+ *  (key_source = local) and the CA chain in chain_path.
+ *  Params:
+ *    - job: job providing paths, modes and ownership.
+ *    - certPEM: issued certificate in PEM format.
+ *  Returns:
+ *    - []ssh.RemoteFile: files to deliver.
+ *    - error: non-nil if the configuration is incomplete.
+ * */
+func deliveryFiles(job *config.Job, certPEM []byte) ([]ssh.RemoteFile, error) {
+
+	if job.Target.CertPath == "" {
+		return nil, fmt.Errorf("delivery %q requires cert_path", job.Target.Delivery)
+	}
+
+	mode, err := parseFileMode(job.Target.FileMode, defaultFileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := []byte(job.Ca.CACertLoaded)
+	if (job.Target.FullChain || job.Target.ChainPath != "") && len(chain) == 0 {
+		job.Log().Warnln("no ca_cert loaded - CA chain is not delivered")
+	}
+
+	cert := append([]byte{}, certPEM...)
+	if job.Target.FullChain {
+		cert = append(cert, chain...)
+	}
+
+	files := []ssh.RemoteFile{{
+		Path: job.Target.CertPath, Data: cert, Mode: mode,
+		Owner: job.Target.FileOwner, Group: job.Target.FileGroup,
+	}}
+	if job.Target.PrivateKey != "" {
+		if job.Target.KeyPath == "" {
+			return nil, fmt.Errorf("key_source = local requires key_path")
+		}
+		keyMode, err := parseFileMode(job.Target.KeyFileMode, defaultKeyFileMode)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ssh.RemoteFile{
+			Path: job.Target.KeyPath, Data: []byte(job.Target.PrivateKey), Mode: keyMode,
+			Owner: job.Target.FileOwner, Group: job.Target.FileGroup,
+		})
+	}
+	if job.Target.ChainPath != "" && len(chain) > 0 {
+		files = append(files, ssh.RemoteFile{
+			Path: job.Target.ChainPath, Data: chain, Mode: mode,
+			Owner: job.Target.FileOwner, Group: job.Target.FileGroup,
+		})
+	}
+	return files, nil
+}
+
+
+/**
+ *  deliverCertificate writes the certificate files of a job to the target.
+ *  Params:
+ *    - job: job to deliver.
+ *    - certPEM: issued certificate in PEM format.
+ *  Returns:
+ *    - error: non-nil if a file could not be written.
+ * */
+func deliverCertificate(job *config.Job, certPEM []byte) error {
+
+	files, err := deliveryFiles(job, certPEM)
+	if err != nil {
+		return err
+	}
+	return ssh.DeliverFiles(sshAddr(job), job.Target.SSHUser, job.Target.SSHKey, hostKeyOptions(job), job.Target.Delivery, files)
+}
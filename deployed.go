@@ -0,0 +1,112 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file implements the optional check of the certificate which is
+ *  actually deployed on the target (read via SSH or presented via TLS).
+ * 
+ * */
+
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
+	"github.com/tseiman/embed-cert-manager/ssh"
+	"github.com/tseiman/embed-cert-manager/tlsprobe"
+)
+
+
+// values of [target] deployed_check
+const (
+	deployedCheckNone = "none"
+	deployedCheckSSH  = "ssh"
+	deployedCheckTLS  = "tls"
+)
+
+
+/**
+ *  checkDeployed compares the certificate deployed on the target with the best valid
+ *  certificate on the CA. If they differ (or the deployed certificate cannot be obtained)
+ *  the returned state requests a renewal. The state is only changed if the CA side
+ *  considers the certificate valid and a deployed_check is configured.
+ *  Params:
+ *    - job: job to check.
+ *    - certState: renewal decision from the CA.
+ *  Returns:
+ *    - ejbcaHttpsClient.CertStatus: possibly updated renewal decision.
+ * */
+func checkDeployed(job *config.Job, certState ejbcaHttpsClient.CertStatus) ejbcaHttpsClient.CertStatus {
+
+	mode := strings.ToLower(strings.TrimSpace(job.Target.DeployedCheck))
+	if certState.Renew || certState.Current == nil || mode == "" || mode == deployedCheckNone {
+		return certState
+	}
+
+	log := job.Log()
+
+	var deployed *x509.Certificate
+	var err error
+
+	switch mode {
+		case deployedCheckSSH:
+			if dryRun {
+				log.Infoln("deployed_check = ssh is skipped in dry-run")
+				return certState
+			}
+			deployed, err = ssh.FetchRemoteCertificate(sshAddr(job), job.Target.SSHUser, job.Target.SSHKey, hostKeyOptions(job), job.Target.CertPath)
+		case deployedCheckTLS:
+			var chain []*x509.Certificate
+			chain, err = tlsprobe.Probe(probeAddress(job), job.Target.ProbeSNI)
+			if err == nil {
+				deployed = chain[0]
+			}
+		default:
+			log.Errorf("unknown deployed_check %q (allowed: %s, %s, %s) - ignored\n", mode, deployedCheckNone, deployedCheckSSH, deployedCheckTLS)
+			return certState
+	}
+
+	if err == nil {
+		err = ejbcaHttpsClient.CompareCertificates(deployed, certState.Current)
+	}
+	if err != nil {
+		log.Warnf("%s: %v -> renew\n", ejbcaHttpsClient.ReasonDeployed, err)
+		certState.Renew = true
+		certState.Reason = fmt.Sprintf("%s (%v)", ejbcaHttpsClient.ReasonDeployed, err)
+		return certState
+	}
+
+	log.Infoln("deployed certificate matches the CA")
+	return certState
+}
+
+
+/**
+ *  probeAddress returns the address used for the TLS probe of a job:
+ *  [target] probe_address or, if not set, the job host on port 443.
+ * */
+func probeAddress(job *config.Job) string {
+	if job.Target.ProbeAddress != "" {
+		return job.Target.ProbeAddress
+	}
+	return net.JoinHostPort(job.Name, "443")
+}
+
+
+/**
+ *  sshAddr returns the SSH address (host:port) of the target of a job.
+ * */
+func sshAddr(job *config.Job) string {
+	return job.Name +":" +  strconv.Itoa(job.Target.SSHPort)
+}
@@ -0,0 +1,90 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file implements the dry-run (plan) output: it shows the renewal
+ *  decision and the rendered target scripts without touching the target or
+ *  requesting a certificate.
+ * 
+ * */
+
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
+)
+
+
+// stands in for the certificate in the rendered install script
+const planCertificatePlaceholder = "<certificate issued by the CA>"
+
+
+/**
+ *  printPlan prints what a run would do for a job: the renewal decision including its
+ *  reason and the rendered csr_command/set_cert_command scripts with secrets masked.
+ *  The plan is written in one piece so parallel jobs do not interleave.
+ *  Params:
+ *    - w: destination of the plan.
+ *    - job: job to plan.
+ *    - certState: renewal decision from the CA.
+ *  Returns:
+ *    - string: statusSkipped or statusWouldRenew.
+ * */
+func printPlan(w io.Writer, job *config.Job, certState ejbcaHttpsClient.CertStatus) string {
+
+	var b strings.Builder
+	status := statusWouldRenew
+
+	fmt.Fprintf(&b, "=== job <%s> ===\n", job.Name)
+	if cur := certState.Current; cur != nil {
+		fmt.Fprintf(&b, "current certificate : serial %s, NotAfter %s\n",
+			ejbcaHttpsClient.CertSerial(cur), cur.NotAfter.Format(time.RFC3339))
+	}
+
+	switch {
+		case certState.Renew:
+			fmt.Fprintf(&b, "decision            : RENEW - %s\n", certState.Reason)
+		case forcePullCert:
+			fmt.Fprintf(&b, "decision            : RENEW - %s, but forced by CLI \"-f\" parameter\n", certState.Reason)
+		default:
+			fmt.Fprintf(&b, "decision            : SKIP - %s\n", certState.Reason)
+			status = statusSkipped
+	}
+
+	if status == statusWouldRenew {
+		// render on a copy, the placeholder must not leak into the real job
+		planJob := *job
+		planJob.Target.Certificate = planCertificatePlaceholder
+
+		if job.ManageEndEntity() {
+			fmt.Fprintf(&b, "end entity          : set to CA %q, certificate profile %q, end entity profile %q\n",
+				job.Ca.CAName, job.Ca.CertProfile, job.Ca.EEProfile)
+			if job.Ca.AutoCreateEndEntity {
+				fmt.Fprintf(&b, "                      created if missing, random one-time password\n")
+			}
+		}
+		if localKeySource(job) {
+			fmt.Fprintf(&b, "key and CSR         : created locally (key_type %q)\n", job.Target.KeyType)
+		} else {
+			fmt.Fprintf(&b, "--- csr_command (rendered) ---\n%s\n", planJob.MaskSecrets(planJob.GetCSRCmd()))
+		}
+		fmt.Fprintf(&b, "--- set_cert_command (rendered) ---\n%s\n", planJob.MaskSecrets(planJob.GetCertSetCmd()))
+		if job.Ca.RevokeSuperseded {
+			fmt.Fprintf(&b, "superseded certs    : revoked after install (reason %s)\n", config.RevocationReasonName(job.Ca.RevocationReason))
+		}
+	}
+
+	io.WriteString(w, b.String())
+	return status
+}
@@ -0,0 +1,145 @@
+package ejbcaHttpsClient
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  Package ejbcaHttpsClient fetches the CA chain of an issued certificate from EJBCA
+ *  using SOAP (gowsdl), so the chain follows CA rollovers without a static ca_cert file.
+ *
+ */
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaws"
+)
+
+
+/**
+ *  GetLastCertChainViaGowsdl fetches the chain of the last certificate issued to the job's
+ *  End Entity using the EJBCA "getLastCertChain" operation.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP call.
+ *    - j: job providing the End Entity name and CA endpoint configuration.
+ *    - hc: HTTP client used by the SOAP client (usually mTLS-configured).
+ *
+ *  Returns:
+ *    - []*x509.Certificate: certificates as returned by EJBCA (usually leaf first).
+ *    - error: non-nil if the SOAP call or decoding fails or the chain is empty.
+ *
+ */
+func GetLastCertChainViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client) ([]*x509.Certificate, error) {
+	if hc == nil {
+		return nil, fmt.Errorf("http client is nil")
+	}
+
+	sc := soap.NewClient(j.Ca.EJBCAApiUrl, soap.WithHTTPClient(hc))
+	ws := ejbcaws.NewEjbcaWS(sc)
+
+	resp, err := ws.GetLastCertChainContext(ctx, &ejbcaws.GetLastCertChain{Arg0: j.Name})
+	if err != nil {
+		return nil, fmt.Errorf("GetLastCertChain SOAP: %w", err)
+	}
+
+	var out []*x509.Certificate
+	for _, item := range resp.Return_ {
+		if item == nil || len(item.CertificateData) == 0 {
+			continue
+		}
+		c, err := parseEJBCAcertData(item.CertificateData, "GetLastCertChainViaGowsdl")
+		if err != nil {
+			return nil, fmt.Errorf("x509 parse: %w", err)
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("GetLastCertChain: empty chain")
+	}
+	return out, nil
+}
+
+
+/**
+ *  OrderCAChain sorts CA certificates by walking up from the leaf: issuing CA first,
+ *  root last. The leaf itself and certificates which are not part of the path are dropped.
+ *
+ *  Params:
+ *    - leaf: certificate whose chain is built.
+ *    - certs: candidate CA certificates in any order (may contain the leaf).
+ *
+ *  Returns:
+ *    - []*x509.Certificate: CA certificates ordered from issuer of leaf to root.
+ *    - error: non-nil if the issuer of leaf is not among certs.
+ *
+ */
+func OrderCAChain(leaf *x509.Certificate, certs []*x509.Certificate) ([]*x509.Certificate, error) {
+
+	var chain []*x509.Certificate
+	used := map[*x509.Certificate]bool{}
+
+	cur := leaf
+	for {
+		// self-signed: root reached
+		if bytes.Equal(cur.RawIssuer, cur.RawSubject) && cur.CheckSignatureFrom(cur) == nil {
+			break
+		}
+		var issuer *x509.Certificate
+		for _, c := range certs {
+			if used[c] || c.Equal(cur) {
+				continue
+			}
+			if bytes.Equal(cur.RawIssuer, c.RawSubject) && cur.CheckSignatureFrom(c) == nil {
+				issuer = c
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		used[issuer] = true
+		chain = append(chain, issuer)
+		cur = issuer
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("issuer %q of the certificate not found in CA chain", leaf.Issuer.String())
+	}
+	return chain, nil
+}
+
+
+/**
+ *  ChainToPEM encodes certificates into one PEM bundle, keeping their order.
+ *
+ *  Params:
+ *    - chain: certificates to encode.
+ *
+ *  Returns:
+ *    - []byte: concatenated PEM blocks.
+ *    - error: non-nil if encoding fails.
+ *
+ */
+func ChainToPEM(chain []*x509.Certificate) ([]byte, error) {
+	var out []byte
+	for _, c := range chain {
+		b, err := CertToPEM(c)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
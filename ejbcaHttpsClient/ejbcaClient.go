@@ -16,14 +16,16 @@ package ejbcaHttpsClient
 
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"net/http"
 	"os"
 	"time"
-	"context"
 	"encoding/pem"
 	"bytes"
+	"fmt"
+	"strings"
 	"github.com/tseiman/embed-cert-manager/config"
 	"github.com/tseiman/embed-cert-manager/logger"
 )
@@ -42,23 +44,23 @@ import (
 func TestConnection(j *config.Job, c *http.Client) bool {
 
 	host := "https://" + j.Ca.Host +"/"
-	logger.Infof("EJBCA test connect to EJBCA %s ... ", host)
+	j.Log().Infof("EJBCA test connect to EJBCA %s ... ", host)
 	// tr can I connect ? first
 	// Try some endpoint of EJBCA first - later we use SOAP
 	req, _ := http.NewRequest("GET", host, nil)
 	resp, err := c.Do(req)
 	if err != nil {
-		logger.Errorf("EJBCA https client - TestConnection %v\n", err)
+		j.Log().Errorf("EJBCA https client - TestConnection %v\n", err)
 		return false
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode > 299 || resp.StatusCode < 200 {
-		logger.Errorf("EJBCA https client - TestConnection return code %s Not OK\n", resp.Status)
+		j.Log().Errorf("EJBCA https client - TestConnection return code %s Not OK\n", resp.Status)
 		return false
 	}
 
-	logger.Debugf(" %s\n", resp.Status)
+	j.Log().Debugf(" %s\n", resp.Status)
 
 	return true
 }
@@ -80,19 +82,19 @@ func NewMTLSClient(j *config.Job) (*http.Client) {
 	// 1) load Client-Certificate
 	cert, err := tls.LoadX509KeyPair(j.Ca.ClientCert, j.Ca.ClientKey)
 	if err != nil {
-		logger.Errorf("EJBCA https client - load client cert/key %v\n",  err)
+		j.Log().Errorf("EJBCA https client - load client cert/key %v\n",  err)
 		return nil
 	}
 
 	// 2) load CA-Pool for Server-Validation
 	caPem, err := os.ReadFile(j.Ca.ServerCertChain)
 	if err != nil {
-		logger.Errorf("EJBCA https client - read server CA file %v\n",  err)
+		j.Log().Errorf("EJBCA https client - read server CA file %v\n",  err)
 		return nil
 	}
 	caPool := x509.NewCertPool()
 	if ok := caPool.AppendCertsFromPEM(caPem); !ok {
-		logger.Errorf("EJBCA https client - append server CA PEM: no certs found\n")
+		j.Log().Errorf("EJBCA https client - append server CA PEM: no certs found\n")
 		return nil
 	}
 
@@ -119,6 +121,27 @@ func NewMTLSClient(j *config.Job) (*http.Client) {
 }
 
 
+// reasons reported by CertState
+const (
+	ReasonValid        = "certificate exists and is valid"
+	ReasonLookupFailed = "certificate lookup at CA failed"
+	ReasonNoCert       = "no certificate found"
+	ReasonExpired      = "no valid certificate (expired or not yet valid)"
+	ReasonInWindow     = "certificate is inside renewal window"
+	ReasonRevoked      = "certificate is revoked"
+	ReasonDeployed     = "deployed certificate differs from CA"
+)
+
+/**
+ *  CertStatus is the result of the renewal decision for a job.
+ *
+ */
+type CertStatus struct {
+	Renew   bool              // true if a renewal is required
+	Reason  string            // one of the Reason* constants
+	Current *x509.Certificate // best valid certificate on the CA, nil if none was found
+}
+
 /**
  *  CheckCertState checks whether a valid certificate already exists on the CA.
  *
@@ -127,38 +150,68 @@ func NewMTLSClient(j *config.Job) (*http.Client) {
  *    - hc: mTLS-configured HTTP client.
  *
  *  Returns:
- *    - bool: true if a valid certificate exists and renewal is not required.
+ *    - bool: true if a renewal is required or the check failed, false if a valid certificate exists.
  *
  */
 func CheckCertState(j *config.Job, hc *http.Client) bool {
-	ctx := context.Background()
+	st, err := CertState(j, hc)
+	return err != nil || st.Renew
+}
+
+/**
+ *  CertState checks whether a valid certificate already exists on the CA and
+ *  returns the decision together with its reason and the certificate it is based on.
+ *
+ *  Params:
+ *    - j: job defining the certificate identity.
+ *    - hc: mTLS-configured HTTP client.
+ *
+ *  Returns:
+ *    - CertStatus: renewal decision, reason and best valid certificate.
+ *    - error: non-nil if the revocation status of the certificate cannot be checked,
+ *      a possibly revoked certificate must not be kept.
+ *
+ */
+func CertState(j *config.Job, hc *http.Client) (CertStatus, error) {
+	ctx := GetContext(j.Name)
 
 	certs, err := FindCertsViaGowsdl(ctx, j, hc, false)
 	if err != nil {
-		logger.Errorf("find certs: %v\n", err)
-	    return true
+		j.Log().Errorf("find certs: %v\n", err)
+	    return CertStatus{Renew: true, Reason: ReasonLookupFailed}, nil
 	}
 
 	if len(certs) == 0 {
-	    logger.Infoln("No certificate found for user -> must enroll/renew")
-	    return true // renew/enroll nötig
+	    j.Log().Infoln("No certificate found for user -> must enroll/renew")
+	    return CertStatus{Renew: true, Reason: ReasonNoCert}, nil // renew/enroll nötig
 	}
 
 	now := time.Now()
 	best := PickBestValidCert(now, certs)
 	if best == nil {
-	    logger.Infoln("No valid certificate found (all expired/notYetValid?) -> must enroll/renew")
-	    return true
+	    j.Log().Infoln("No valid certificate found (all expired/notYetValid?) -> must enroll/renew")
+	    return CertStatus{Renew: true, Reason: ReasonExpired}, nil
+	}
+
+	// a revoked certificate is still inside its validity period, so ask the CA
+	revocation, err := CheckRevocationViaGowsdl(ctx, j, hc, best)
+	if err != nil {
+		return CertStatus{Current: best}, fmt.Errorf("revocation status check: %w", err)
+	}
+	if revocation.Revoked {
+		j.Log().Warnf("Certificate serial %s was revoked on %s (reason %s) -> must enroll/renew\n",
+			CertSerial(best), revocation.Date.Format(time.RFC3339), config.RevocationReasonName(revocation.Reason))
+		return CertStatus{Renew: true, Reason: ReasonRevoked, Current: best}, nil
 	}
 
 	if NeedsRenew(now, best, time.Duration(j.Target.ChangeAfter) * time.Second) {
 
-	    logger.Infoln("Certificate exists but is within renewal window -> renew")
-	    return true
+	    j.Log().Infoln("Certificate exists but is within renewal window -> renew")
+	    return CertStatus{Renew: true, Reason: ReasonInWindow, Current: best}, nil
 	}
 
-	logger.Infoln("Certificate exists and is still valid -> no renew")
-	return false
+	j.Log().Infoln("Certificate exists and is still valid -> no renew")
+	return CertStatus{Renew: false, Reason: ReasonValid, Current: best}, nil
 
 }
 
@@ -176,41 +229,57 @@ func CheckCertState(j *config.Job, hc *http.Client) bool {
  */
 func EnrollOrRenewCert(j *config.Job, hc *http.Client, csrPEM []byte) (*x509.Certificate) {
 
-	ctx := GetContext()
+	ctx := GetContext(j.Name)
+
+	password := j.Ca.Password
+
+	// profiles, CA, DN and SANs of the End Entity are taken from the job if configured
+	if j.Ca.AutoCreateEndEntity {
+		otp, err := provisionEndEntity(ctx, j, hc)
+		if err != nil {
+			j.Log().Errorf("EJBCA End Entity provisioning failed for %q: %v\n", j.Name, err)
+			return nil
+		}
+		defer logger.RemoveSecret(otp)
+		password = otp
+	} else if j.ManageEndEntity() {
+		j.Log().Infof("EJBCA set End Entity %q: CA %q, certificate profile %q, End Entity profile %q\n",
+			j.Name, j.Ca.CAName, j.Ca.CertProfile, j.Ca.EEProfile)
+		if err := EditUserViaGowsdl(ctx, j, hc, EndEntityData(j, j.Ca.Password)); err != nil {
+			j.Log().Errorf("EJBCA End Entity update failed for %q: %v\n", j.Name, err)
+			return nil
+		}
+	}
+
 	// ---- Parameters for PKCS10 ----
 	p := Pkcs10Params{
 		Username: j.Name,     // End Entity username (host/device name)
-		Password: j.Ca.Password,         // oft leer erlaubt; sonst End Entity Password / OTP
+		Password: password,         // oft leer erlaubt; sonst End Entity Password / OTP
 		CSRPEM:   csrPEM,     // -----BEGIN CERTIFICATE REQUEST-----
-		
-		// Optional – in vielen Setups leer lassen:
-	//	CertProfile: j.Ca.CertProfile,    	 // z.B. "TLS-Server"
-	//	CAName:      j.Ca.CAName,     		 // z.B. "TseiWebCA"
-	// ResponseType: 	j.Ca.ResponseType,
 	}
 
 	cert, err := Pkcs10RequestViaGowsdl(ctx, j, hc, p)
 	if err != nil {
 		// SOAP / Auth / Profile / CSR Fehler landen hier
-		logger.Errorf("EJBCA pkcs10 enroll failed for %q: %v\n", j.Name, err)
+		j.Log().Errorf("EJBCA pkcs10 enroll failed for %q: %v\n", j.Name, err)
 		return nil
 	}
 
 	// ---- Sanity checks (optional, aber empfohlen) ----
 	if time.Now().After(cert.NotAfter) {
-		logger.Errorf("received certificate already expired (%s)\n", cert.NotAfter)
+		j.Log().Errorf("received certificate already expired (%s)\n", cert.NotAfter)
 		return nil
 	}
 
 	if err := cert.VerifyHostname(j.Name); err != nil {
 		// je nach SAN/DNS Setup evtl. nur warnen
-		logger.Warnf("hostname verification failed: %v", err)
+		j.Log().Warnf("hostname verification failed: %v", err)
 	}
 
-	logger.Infof(
+	j.Log().Infof(
 		"received certificate: CN=%q Serial=%s NotAfter=%s",
 		cert.Subject.CommonName,
-		cert.SerialNumber.String(),
+		CertSerial(cert),
 		cert.NotAfter.Format(time.RFC3339),
 	)
 
@@ -241,3 +310,39 @@ func CertToPEM(cert *x509.Certificate) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+
+/**
+ *  CertSerial returns the serial number of a certificate in upper case hex, the notation
+ *  of EJBCA. It is used for the SOAP calls, the logs, the job state and the report.
+ *
+ *  Params:
+ *    - cert: certificate.
+ *
+ *  Returns:
+ *    - string: serial number, e.g. "5F3A0C...".
+ *
+ */
+func CertSerial(cert *x509.Certificate) string {
+	return strings.ToUpper(cert.SerialNumber.Text(16))
+}
+
+
+/**
+ *  CertFingerprint returns the SHA-256 fingerprint of a certificate in the
+ *  colon separated hex notation used by "openssl x509 -fingerprint -sha256".
+ *
+ *  Params:
+ *    - cert: certificate to fingerprint.
+ *
+ *  Returns:
+ *    - string: fingerprint, e.g. "AB:CD:...".
+ *
+ */
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -9,7 +9,8 @@ package ejbcaHttpsClient
  *  with limited software capabilities.
  * 
  *  Package ejbcaHttpsClient provides helpers to communicate with EJBCA via HTTPS/SOAP.
- *  This file manages a reusable context with a configurable timeout to control SOAP calls.
+ *  This file manages reusable per-job contexts with a configurable timeout to control SOAP calls.
+ *  Contexts are stored per job name so jobs can safely run in parallel.
  *
  */
 
@@ -19,31 +20,43 @@ import (
 	"time"
 )
 
+/**
+ *  storedContext holds a context together with the function cancelling it.
+ *
+ */
+type storedContext struct {
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
 var (
 	ctxMu      sync.Mutex
-	storedCtx  context.Context
-	storedStop context.CancelFunc
+	storedCtxs = map[string]storedContext{}
 	defaultTimeout = 2 * time.Minute
 )
 
 /**
- *  GetContext returns a stored context for EJBCA operations.
- *  If no context is stored yet, it will create one using the default timeout.
+ *  GetContext returns the stored context of a job for EJBCA operations.
+ *  If no context is stored yet for this job, it will create one using the default timeout.
+ *
+ *  Params:
+ *    - job: name of the job the context belongs to.
  *
  *  Returns:
  *    - context.Context: the stored (or newly created) context.
  *
  */
-func GetContext() context.Context {
-	return GetContextRenewed(false, 0)
+func GetContext(job string) context.Context {
+	return GetContextRenewed(job, false, 0)
 }
 
 /**
- *  GetContextRenewed returns a stored context and optionally renews it.
- *  If renew is true, any previously stored context is cancelled and a new context is created.
+ *  GetContextRenewed returns the stored context of a job and optionally renews it.
+ *  If renew is true, any previously stored context of the job is cancelled and a new context is created.
  *  If renew is false, the existing stored context is returned if present; otherwise a new one is created.
  *
  *  Params:
+ *    - job: name of the job the context belongs to.
  *    - renew: whether to force creation of a new context and cancel the previous one.
  *    - tout: timeout to use when creating a new context. If <= 0, a default timeout is used.
  *
@@ -51,22 +64,22 @@ func GetContext() context.Context {
  *    - context.Context: the stored (possibly renewed) context.
  *
  */
-func GetContextRenewed(renew bool, tout time.Duration) context.Context {
+func GetContextRenewed(job string, renew bool, tout time.Duration) context.Context {
 	ctxMu.Lock()
 	defer ctxMu.Unlock()
 
-	if renew {
+	sc, ok := storedCtxs[job]
+
+	if renew && ok {
 		// alten (intern erzeugten) Context beenden
-		if storedStop != nil {
-			storedStop()
-		}
-		storedStop = nil
-		storedCtx = nil // <-- WICHTIG: damit wirklich neu erzeugt wird
+		sc.stop()
+		delete(storedCtxs, job) // <-- WICHTIG: damit wirklich neu erzeugt wird
+		ok = false
 	}
 
 	// bereits vorhanden?
-	if storedCtx != nil {
-		return storedCtx
+	if ok {
+		return sc.ctx
 	}
 
 	// timeout wählen
@@ -76,23 +89,24 @@ func GetContextRenewed(renew bool, tout time.Duration) context.Context {
 
 	// neuen erzeugen
 	newCtx, cancel := context.WithTimeout(context.Background(), tout)
-	storedCtx = newCtx
-	storedStop = cancel
-	return storedCtx
+	storedCtxs[job] = storedContext{ctx: newCtx, stop: cancel}
+	return newCtx
 }
 
 /**
- *  CancelStoredContext cancels the currently stored context (if any) and clears internal references.
+ *  CancelStoredContext cancels the stored context of a job (if any) and clears internal references.
  *  This is useful to stop in-flight operations and ensure a fresh context is created next time.
  *
+ *  Params:
+ *    - job: name of the job the context belongs to.
+ *
  */
-func CancelStoredContext() {
+func CancelStoredContext(job string) {
 	ctxMu.Lock()
 	defer ctxMu.Unlock()
 
-	if storedStop != nil {
-		storedStop()
+	if sc, ok := storedCtxs[job]; ok {
+		sc.stop()
+		delete(storedCtxs, job)
 	}
-	storedStop = nil
-	storedCtx = nil
 }
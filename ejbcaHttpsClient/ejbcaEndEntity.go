@@ -0,0 +1,258 @@
+package ejbcaHttpsClient
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  Package ejbcaHttpsClient configures EJBCA End Entities using SOAP (gowsdl), so that
+ *  certificate profile, End Entity profile, CA, DN and SANs are taken from the job.
+ *
+ */
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaws"
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+const (
+	// EJBCA End Entity status which allows one enrollment with the password
+	endEntityStatusNew int32 = 10
+
+	tokenTypeUserGenerated = "USERGENERATED"
+)
+
+
+/**
+ *  EndEntityData builds the EJBCA End Entity of a job: username and CN are the job host,
+ *  SANs, profiles and CA come from the job configuration.
+ *
+ *  Params:
+ *    - j: job describing the End Entity.
+ *    - password: enrollment password set for the End Entity.
+ *
+ *  Returns:
+ *    - *ejbcaws.UserDataVOWS: End Entity with status NEW.
+ *
+ */
+func EndEntityData(j *config.Job, password string) *ejbcaws.UserDataVOWS {
+	ud := &ejbcaws.UserDataVOWS{
+		Username:               j.Name,
+		Password:               password,
+		SubjectDN:              "CN=" + j.Name,
+		SubjectAltName:         ejbcaSubjectAltName(j.Target.SANs),
+		CaName:                 j.Ca.CAName,
+		CertificateProfileName: j.Ca.CertProfile,
+		EndEntityProfileName:   j.Ca.EEProfile,
+		TokenType:              tokenTypeUserGenerated,
+		Status:                 endEntityStatusNew,
+	}
+	if len(j.Target.SANs.Email) > 0 {
+		ud.Email = j.Target.SANs.Email[0]
+	}
+	return ud
+}
+
+
+/**
+ *  EditUserViaGowsdl creates or overwrites an End Entity using the EJBCA "editUser" operation.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP call.
+ *    - j: job containing CA endpoint configuration.
+ *    - hc: HTTP client used by the SOAP client (usually mTLS-configured).
+ *    - ud: End Entity data.
+ *
+ *  Returns:
+ *    - error: non-nil if the SOAP call fails.
+ *
+ */
+func EditUserViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client, ud *ejbcaws.UserDataVOWS) error {
+	if hc == nil {
+		return fmt.Errorf("http client is nil")
+	}
+
+	sc := soap.NewClient(j.Ca.EJBCAApiUrl, soap.WithHTTPClient(hc))
+	ws := ejbcaws.NewEjbcaWS(sc)
+
+	if _, err := ws.EditUserContext(ctx, &ejbcaws.EditUser{Arg0: ud}); err != nil {
+		return fmt.Errorf("EditUser SOAP (%s): %w", ud.Username, err)
+	}
+	return nil
+}
+
+
+/**
+ *  ejbcaSubjectAltName renders SANs in the EJBCA notation,
+ *  e.g. "dNSName=web.domain.tld, iPAddress=10.1.1.1".
+ *
+ */
+func ejbcaSubjectAltName(san config.SubjectAltNames) string {
+	var parts []string
+	for _, d := range san.DNS {
+		parts = append(parts, "dNSName="+d)
+	}
+	for _, ip := range san.IP {
+		parts = append(parts, "iPAddress="+ip.String())
+	}
+	for _, u := range san.URI {
+		parts = append(parts, "uniformResourceIdentifier="+u.String())
+	}
+	for _, e := range san.Email {
+		parts = append(parts, "rfc822Name="+e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+
+// EJBCA UserMatch values for "username equals"
+const (
+	matchWithUsername int32 = 0
+	matchTypeEquals   int32 = 0
+)
+
+/**
+ *  FindUserViaGowsdl looks up the job's End Entity using the EJBCA "findUser" operation.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP call.
+ *    - j: job providing the End Entity name and CA endpoint configuration.
+ *    - hc: HTTP client used by the SOAP client (usually mTLS-configured).
+ *
+ *  Returns:
+ *    - *ejbcaws.UserDataVOWS: End Entity, nil if it does not exist.
+ *    - error: non-nil if the SOAP call fails.
+ *
+ */
+func FindUserViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client) (*ejbcaws.UserDataVOWS, error) {
+	if hc == nil {
+		return nil, fmt.Errorf("http client is nil")
+	}
+
+	sc := soap.NewClient(j.Ca.EJBCAApiUrl, soap.WithHTTPClient(hc))
+	ws := ejbcaws.NewEjbcaWS(sc)
+
+	resp, err := ws.FindUserContext(ctx, &ejbcaws.FindUser{Arg0: &ejbcaws.UserMatch{
+		Matchwith:  matchWithUsername,
+		Matchtype:  matchTypeEquals,
+		Matchvalue: j.Name,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("FindUser SOAP (%s): %w", j.Name, err)
+	}
+	for _, ud := range resp.Return_ {
+		if ud != nil && ud.Username == j.Name {
+			return ud, nil
+		}
+	}
+	return nil, nil
+}
+
+
+/**
+ *  randomEnrollmentPassword creates a one-time End Entity password.
+ *  It is only held in memory for the enrollment it is created for and masked in all logs.
+ *
+ */
+func randomEnrollmentPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	otp := base64.RawURLEncoding.EncodeToString(b)
+	logger.AddSecret(otp)
+	return otp, nil
+}
+
+
+/**
+ *  provisionEndEntity creates the job's End Entity if it does not exist yet, or updates an
+ *  existing one, with a new random one-time password (auto_create_end_entity). DN, SANs,
+ *  CA and profiles are taken from the job, the other values of an existing End Entity
+ *  (e.g. notification and key recovery settings) are kept.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP calls.
+ *    - j: job describing the End Entity.
+ *    - hc: mTLS-configured HTTP client.
+ *
+ *  Returns:
+ *    - string: one-time password to enroll with.
+ *    - error: non-nil if lookup, password generation or EditUser fails.
+ *
+ */
+func provisionEndEntity(ctx context.Context, j *config.Job, hc *http.Client) (string, error) {
+
+	existing, err := FindUserViaGowsdl(ctx, j, hc)
+	if err != nil {
+		return "", err
+	}
+
+	otp, err := randomEnrollmentPassword()
+	if err != nil {
+		return "", fmt.Errorf("generate enrollment password: %w", err)
+	}
+
+	var ud *ejbcaws.UserDataVOWS
+	switch {
+		case existing == nil:
+			j.Log().Infof("EJBCA End Entity %q not found - creating it (CA %q, certificate profile %q, End Entity profile %q)\n",
+				j.Name, j.Ca.CAName, j.Ca.CertProfile, j.Ca.EEProfile)
+			ud = EndEntityData(j, otp)
+		case j.ManageEndEntity():
+			j.Log().Infof("EJBCA End Entity %q exists - updating it (CA %q, certificate profile %q, End Entity profile %q) with a new one-time password\n",
+				j.Name, j.Ca.CAName, j.Ca.CertProfile, j.Ca.EEProfile)
+			ud = mergeEndEntity(EndEntityData(j, otp), existing)
+		default:
+			j.Log().Infof("EJBCA End Entity %q exists - resetting status and setting new one-time password\n", j.Name)
+			ud = existing
+			ud.Password = otp
+			ud.Status = endEntityStatusNew
+	}
+
+	if err := EditUserViaGowsdl(ctx, j, hc, ud); err != nil {
+		return "", err
+	}
+	return otp, nil
+}
+
+
+/**
+ *  mergeEndEntity copies the values the job does not configure from an existing
+ *  End Entity into the End Entity built from the job.
+ *
+ *  Params:
+ *    - ud: End Entity built by EndEntityData.
+ *    - existing: End Entity returned by findUser.
+ *
+ *  Returns:
+ *    - *ejbcaws.UserDataVOWS: ud with the kept values.
+ *
+ */
+func mergeEndEntity(ud, existing *ejbcaws.UserDataVOWS) *ejbcaws.UserDataVOWS {
+	if ud.Email == "" {
+		ud.Email = existing.Email
+	}
+	ud.CardNumber = existing.CardNumber
+	ud.ClearPwd = existing.ClearPwd
+	ud.KeyRecoverable = existing.KeyRecoverable
+	ud.SendNotification = existing.SendNotification
+	ud.HardTokenIssuerName = existing.HardTokenIssuerName
+	ud.StartTime = existing.StartTime
+	ud.EndTime = existing.EndTime
+	ud.ExtendedInformation = existing.ExtendedInformation
+	return ud
+}
@@ -75,7 +75,7 @@ func Pkcs10RequestViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client,
 	if csrB64 == "" {
 	    return nil, fmt.Errorf("csrB64 is empty")
 	}
-	logger.Debugf("csrB64 len=%d prefix=%q", len(csrB64), csrB64[:min(16,len(csrB64))])
+	j.Log().Debugf("csrB64 len=%d prefix=%q", len(csrB64), csrB64[:min(16,len(csrB64))])
 
 	req := &ejbcaws.Pkcs10Request{
 		XmlnsNs1: "http://ws.protocol.core.ejbca.org/",
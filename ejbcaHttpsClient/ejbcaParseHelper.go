@@ -147,6 +147,32 @@ func NeedsRenew(now time.Time, cert *x509.Certificate, changeBefore time.Duratio
 	return needs
 }
 
+/**
+ *  CompareCertificates checks whether two certificates are the same by comparing
+ *  serial number, NotAfter and public key.
+ *
+ *  Params:
+ *    - deployed: certificate found on the target.
+ *    - expected: certificate expected (e.g. the best valid certificate on the CA).
+ *
+ *  Returns:
+ *    - error: nil if both match, otherwise a description of the first difference.
+ *
+ */
+func CompareCertificates(deployed, expected *x509.Certificate) error {
+	if deployed.SerialNumber.Cmp(expected.SerialNumber) != 0 {
+		return fmt.Errorf("serial %s, expected %s", CertSerial(deployed), CertSerial(expected))
+	}
+	if !deployed.NotAfter.Equal(expected.NotAfter) {
+		return fmt.Errorf("NotAfter %s, expected %s",
+			deployed.NotAfter.Format(time.RFC3339), expected.NotAfter.Format(time.RFC3339))
+	}
+	if !bytes.Equal(deployed.RawSubjectPublicKeyInfo, expected.RawSubjectPublicKeyInfo) {
+		return fmt.Errorf("public key differs")
+	}
+	return nil
+}
+
 /**
  *  trimToASN1Object attempts to trim input data to a plausible single ASN.1 object.
  *  This is used as a robustness helper when the input contains whitespace or extra bytes
@@ -0,0 +1,196 @@
+package ejbcaHttpsClient
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  Package ejbcaHttpsClient implements certificate revocation against EJBCA using SOAP (gowsdl).
+ *
+ */
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaws"
+)
+
+
+/**
+ *  RevokeCertViaGowsdl revokes a single certificate using the EJBCA "revokeCert" operation.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP call.
+ *    - j: job containing CA endpoint configuration.
+ *    - hc: HTTP client used by the SOAP client (usually mTLS-configured).
+ *    - cert: certificate to revoke (issuer DN and serial are sent).
+ *    - reason: RFC 5280 revocation reason code.
+ *
+ *  Returns:
+ *    - error: non-nil if the SOAP call fails.
+ *
+ */
+func RevokeCertViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client, cert *x509.Certificate, reason int32) error {
+	if hc == nil {
+		return fmt.Errorf("http client is nil")
+	}
+
+	sc := soap.NewClient(j.Ca.EJBCAApiUrl, soap.WithHTTPClient(hc))
+	ws := ejbcaws.NewEjbcaWS(sc)
+
+	req := &ejbcaws.RevokeCert{
+		Arg0: cert.Issuer.String(),
+		Arg1: CertSerial(cert), // EJBCA expects the serial in hex
+		Arg2: reason,
+	}
+
+	if _, err := ws.RevokeCertContext(ctx, req); err != nil {
+		return fmt.Errorf("RevokeCert SOAP (serial %s): %w", CertSerial(cert), err)
+	}
+	return nil
+}
+
+
+/**
+ *  RevokeSuperseded revokes all still valid certificates of the job except the current one,
+ *  using the revocation reason configured for the job.
+ *
+ *  Params:
+ *    - j: job whose older certificates are revoked.
+ *    - hc: mTLS-configured HTTP client.
+ *    - current: newly issued certificate which must stay valid.
+ *
+ *  Returns:
+ *    - []string: serials (hex) of the revoked certificates.
+ *    - error: non-nil if the lookup or a revocation fails; revoked serials up to then are returned.
+ *
+ */
+func RevokeSuperseded(j *config.Job, hc *http.Client, current *x509.Certificate) ([]string, error) {
+	ctx := GetContext(j.Name)
+
+	certs, err := FindCertsViaGowsdl(ctx, j, hc, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var revoked []string
+	for _, c := range certs {
+		if c.SerialNumber.Cmp(current.SerialNumber) == 0 || now.After(c.NotAfter) {
+			continue
+		}
+		if err := RevokeCertViaGowsdl(ctx, j, hc, c, j.Ca.RevocationReason); err != nil {
+			return revoked, err
+		}
+		j.Log().Infof("revoked superseded certificate serial %s (reason %s)\n",
+			CertSerial(c), config.RevocationReasonName(j.Ca.RevocationReason))
+		revoked = append(revoked, CertSerial(c))
+	}
+	return revoked, nil
+}
+
+
+/**
+ *  RevokeUserViaGowsdl revokes all certificates of the job's End Entity using the EJBCA
+ *  "revokeUser" operation. The End Entity is set to status revoked, so it cannot enroll again
+ *  until it is reactivated on the CA.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP call.
+ *    - j: job providing the End Entity name and CA endpoint configuration.
+ *    - hc: HTTP client used by the SOAP client (usually mTLS-configured).
+ *    - reason: RFC 5280 revocation reason code.
+ *
+ *  Returns:
+ *    - error: non-nil if the SOAP call fails.
+ *
+ */
+func RevokeUserViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client, reason int32) error {
+	if hc == nil {
+		return fmt.Errorf("http client is nil")
+	}
+
+	sc := soap.NewClient(j.Ca.EJBCAApiUrl, soap.WithHTTPClient(hc))
+	ws := ejbcaws.NewEjbcaWS(sc)
+
+	req := &ejbcaws.RevokeUser{
+		Arg0: j.Name,
+		Arg1: reason,
+		Arg2: false, // keep the End Entity
+	}
+
+	if _, err := ws.RevokeUserContext(ctx, req); err != nil {
+		return fmt.Errorf("RevokeUser SOAP (%s): %w", j.Name, err)
+	}
+	return nil
+}
+
+
+// reason code reported by EJBCA for certificates which are not revoked
+const notRevoked int32 = -1
+
+/**
+ *  RevocationStatus is the revocation state of a certificate on the CA.
+ *
+ */
+type RevocationStatus struct {
+	Revoked bool
+	Reason  int32     // RFC 5280 reason code, only valid if Revoked
+	Date    time.Time // revocation date, only valid if Revoked
+}
+
+/**
+ *  CheckRevocationViaGowsdl queries the revocation state of a certificate using the
+ *  EJBCA "checkRevokationStatus" operation.
+ *
+ *  Params:
+ *    - ctx: context controlling cancellation and timeouts for the SOAP call.
+ *    - j: job containing CA endpoint configuration.
+ *    - hc: HTTP client used by the SOAP client (usually mTLS-configured).
+ *    - cert: certificate to check (issuer DN and serial are sent).
+ *
+ *  Returns:
+ *    - RevocationStatus: revocation state of the certificate.
+ *    - error: non-nil if the SOAP call fails or the CA does not know the certificate.
+ *
+ */
+func CheckRevocationViaGowsdl(ctx context.Context, j *config.Job, hc *http.Client, cert *x509.Certificate) (RevocationStatus, error) {
+	if hc == nil {
+		return RevocationStatus{}, fmt.Errorf("http client is nil")
+	}
+
+	sc := soap.NewClient(j.Ca.EJBCAApiUrl, soap.WithHTTPClient(hc))
+	ws := ejbcaws.NewEjbcaWS(sc)
+
+	req := &ejbcaws.CheckRevokationStatus{
+		Arg0: cert.Issuer.String(),
+		Arg1: CertSerial(cert),
+	}
+
+	resp, err := ws.CheckRevokationStatusContext(ctx, req)
+	if err != nil {
+		return RevocationStatus{}, fmt.Errorf("CheckRevokationStatus SOAP (serial %s): %w", CertSerial(cert), err)
+	}
+	if resp == nil || resp.Return_ == nil {
+		return RevocationStatus{}, fmt.Errorf("CheckRevokationStatus: certificate serial %s not known by the CA", CertSerial(cert))
+	}
+
+	if resp.Return_.Reason == notRevoked {
+		return RevocationStatus{}, nil
+	}
+	return RevocationStatus{
+		Revoked: true,
+		Reason:  resp.Return_.Reason,
+		Date:    resp.Return_.RevocationDate.ToGoTime(),
+	}, nil
+}
@@ -4,8 +4,13 @@ go 1.25
 
 require (
 	github.com/hooklift/gowsdl v0.5.0
+	github.com/pkg/sftp v1.13.9
 	golang.org/x/crypto v0.47.0
 	gopkg.in/ini.v1 v1.67.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.40.0 // indirect
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+)
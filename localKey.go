@@ -0,0 +1,73 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file implements [target] key_source = local: the private key and
+ *  the CSR are created by the tool instead of by csr_command on the target.
+ * 
+ * */
+
+
+import (
+	"strings"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/csrgen"
+)
+
+
+const (
+	keySourceTarget = "target" // csr_command creates key and CSR on the target
+	keySourceLocal  = "local"  // key and CSR are created locally
+)
+
+
+/**
+ *  localKeySource reports whether key and CSR of a job are created locally.
+ * */
+func localKeySource(job *config.Job) bool {
+	return strings.ToLower(strings.TrimSpace(job.Target.KeySource)) == keySourceLocal
+}
+
+
+/**
+ *  createLocalCSR generates a new private key and a CSR for the job host and its
+ *  subjectAltName. The PEM encoded key is kept in memory only (job.Target.PrivateKey)
+ *  until it is shipped to the target by the install step.
+ *  Params:
+ *    - job: job to create key and CSR for.
+ *  Returns:
+ *    - string: CSR in PEM format.
+ *    - error: non-nil if key or CSR generation fails.
+ * */
+func createLocalCSR(job *config.Job) (string, error) {
+
+	key, err := csrgen.GenerateKey(job.Target.KeyType, job.Target.KeySize, job.Target.KeyCurve)
+	if err != nil {
+		return "", err
+	}
+
+	csrPEM, err := csrgen.CreateCSR(key, job.Name, job.Target.SANs)
+	if err != nil {
+		return "", err
+	}
+
+	keyPEM, err := csrgen.KeyToPEM(key)
+	if err != nil {
+		return "", err
+	}
+	job.Target.PrivateKey = string(keyPEM)
+	job.RegisterSecrets()
+
+	if !nativeDelivery(job) && !strings.Contains(job.Target.SetCertCommand, "${target_private_key}") {
+		job.Log().Warnln("key_source = local but set_cert_command does not use ${target_private_key} - the new key is not installed")
+	}
+
+	return string(csrPEM), nil
+}
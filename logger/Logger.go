@@ -5,7 +5,9 @@ import (
 	"log"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 )
 
@@ -46,10 +48,53 @@ func callerShort(depth int) string {
 	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
 }
 
+// ---- Secrets, replaced by "********" in every log line ----
+var (
+	secretsMu sync.RWMutex
+	secrets   []string // longest first, so a secret containing another one is masked as a whole
+)
+
+func AddSecret(s string) {
+	if s == "" {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, known := range secrets {
+		if known == s {
+			return
+		}
+	}
+	secrets = append(secrets, s)
+	sort.Slice(secrets, func(i, k int) bool { return len(secrets[i]) > len(secrets[k]) })
+}
+
+// RemoveSecret stops masking a secret which is no longer in use (e.g. a one-time password).
+func RemoveSecret(s string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for i, known := range secrets {
+		if known == s {
+			secrets = append(secrets[:i], secrets[i+1:]...)
+			return
+		}
+	}
+}
+
+func maskSecrets(msg string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, s := range secrets {
+		msg = strings.ReplaceAll(msg, s, "********")
+	}
+	return msg
+}
+
 func output(l Level, msg string) {
 	if !enabled(l) {
 		return
 	}
+	msg = maskSecrets(msg)
 
 	// Put level first, then file:line, then message.
 	loc := callerShort(3)
@@ -74,3 +119,18 @@ func Debugln(v ...any) { output(LevelDebug, strings.TrimRight(fmt.Sprintln(v...)
 
 
 
+
+// ---- Prefixed logger, e.g. one per job so interleaved output stays readable ----
+type Prefixed string
+
+func WithPrefix(p string) Prefixed { return Prefixed("[" + p + "] ") }
+
+func (p Prefixed) Errorf(format string, v ...any) { output(LevelError, string(p)+fmt.Sprintf(format, v...)) }
+func (p Prefixed) Warnf(format string, v ...any)  { output(LevelWarn,  string(p)+fmt.Sprintf(format, v...)) }
+func (p Prefixed) Infof(format string, v ...any)  { output(LevelInfo,  string(p)+fmt.Sprintf(format, v...)) }
+func (p Prefixed) Debugf(format string, v ...any) { output(LevelDebug, string(p)+fmt.Sprintf(format, v...)) }
+
+func (p Prefixed) Errorln(v ...any) { output(LevelError, string(p)+strings.TrimRight(fmt.Sprintln(v...), "\n")) }
+func (p Prefixed) Warnln(v ...any)  { output(LevelWarn,  string(p)+strings.TrimRight(fmt.Sprintln(v...), "\n")) }
+func (p Prefixed) Infoln(v ...any)  { output(LevelInfo,  string(p)+strings.TrimRight(fmt.Sprintln(v...), "\n")) }
+func (p Prefixed) Debugln(v ...any) { output(LevelDebug, string(p)+strings.TrimRight(fmt.Sprintln(v...), "\n")) }
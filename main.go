@@ -19,15 +19,15 @@ package main
 import (
 	"flag"
 	"fmt"
-	"strconv"
+	"io"
 	"strings"
 	"os"
-	"log"
+	"path/filepath"
 	"time"
 	"github.com/tseiman/embed-cert-manager/config"
 	"github.com/tseiman/embed-cert-manager/ssh"
-	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
 	"github.com/tseiman/embed-cert-manager/logger"
+	"github.com/tseiman/embed-cert-manager/state"
 )
 
 
@@ -36,6 +36,13 @@ const (
 	defaultForceCert    = false
 	defaultLogLevel     = "warn"
 	defaultVersionFlag  = false
+	defaultParallelJobs = 1
+	defaultDaemonFlag   = false
+	defaultMinInterval  = "1h"
+	defaultMaxInterval  = "1d"
+	defaultJitter       = "10m"
+	defaultStateFile    = "state.json"
+	defaultRevokeReason = "unspecified"
 
 )
 
@@ -43,6 +50,20 @@ var configPath string
 var forcePullCert bool
 var logLevel string
 var versionFlag bool
+var parallelJobs int
+var daemonFlag bool
+var minIntervalRaw string
+var maxIntervalRaw string
+var jitterRaw string
+var stateFile string
+var reportPath string
+var dryRun bool
+var revokeReason string
+var revokeAll bool
+var revokeDisable bool
+var jobNames stringList
+var jobTags stringList
+var expiringWithinRaw string
 
 var version     = "<no version set>" // per ldflags überschreibbar
 
@@ -58,6 +79,23 @@ func initFlags() {
 	flag.BoolVar  (&versionFlag, 	"version", 	defaultVersionFlag,	"")
 	flag.StringVar(&logLevel, 		"l", 		defaultLogLevel, 	"")
 	flag.StringVar(&logLevel, 		"loglevel", defaultLogLevel, 	"")
+	flag.IntVar   (&parallelJobs, 	"p", 		defaultParallelJobs,"")
+	flag.IntVar   (&parallelJobs, 	"parallel", defaultParallelJobs,"")
+	flag.BoolVar  (&daemonFlag, 	"d", 		defaultDaemonFlag,	"")
+	flag.BoolVar  (&daemonFlag, 	"daemon", 	defaultDaemonFlag,	"")
+	flag.StringVar(&minIntervalRaw, "min-interval", defaultMinInterval, "")
+	flag.StringVar(&maxIntervalRaw, "max-interval", defaultMaxInterval, "")
+	flag.StringVar(&jitterRaw, 		"jitter", 	defaultJitter, 		"")
+	flag.StringVar(&stateFile, 		"state", 	"", 				"")
+	flag.StringVar(&reportPath, 	"report", 	"", 				"")
+	flag.BoolVar  (&dryRun, 		"n", 		false, 				"")
+	flag.BoolVar  (&dryRun, 		"dry-run", 	false, 				"")
+	flag.StringVar(&revokeReason, 	"reason", 	defaultRevokeReason,"")
+	flag.BoolVar  (&revokeAll, 		"all", 		false, 				"")
+	flag.BoolVar  (&revokeDisable, 	"disable", 	false, 				"")
+	flag.Var      (&jobNames, 		"job", 							"")
+	flag.Var      (&jobTags, 		"tag", 							"")
+	flag.StringVar(&expiringWithinRaw, "expiring-within", "", 		"")
 }
 
 
@@ -65,23 +103,78 @@ func initFlags() {
  *  initFlags defines and registers command-line flags used by the CLI.
  * */
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] [command] [args]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintf(os.Stderr,
+		"  run                      Check and renew the certificates of all jobs (default)\n"+
+		"\n"+
+		"  status [job ...]         Prints the recorded state of all (or the given) jobs\n"+
+		"\n"+
+		"  check-config             Validates all enabled jobs strictly and prints every problem\n"+
+		"                           with file and line (alias: lint). \"run\" refuses jobs with problems\n"+
+		"\n"+
+		"  revoke <job>             Revokes the current certificate of a job at the CA\n"+
+		"    --reason <reason>      Revocation reason, e.g. keyCompromise (default: %s)\n"+
+		"    --all                  Revoke all certificates of the job's End Entity\n"+
+		"    --disable              Set \"enabled = false\" in the job file afterwards\n"+
+		"\n", defaultRevokeReason)
 	fmt.Fprintln(os.Stderr, "Options:")
 	fmt.Fprintf(os.Stderr,
 		"  -c, --config  <path>     Configuration path to read *.conf files from.\n"+
 		"                           (default: %s)\n"+
 		"\n"+
 		"  -f, --force              Force generation and poll of Zertifikate \n"+
-		"                           even it is still valid (default: false)\n"+
+		"                           even it is still valid (default: false).\n"+
+		"                           Not allowed with --daemon\n"+
 		"\n"+
 		"  -l, --loglevel <level>   Sets a verbosity level. Default is \"warn\". \n"+
 		"                           Possible level: error | warn | info | debug\n"+
 		"\n"+
+		"  -n, --dry-run            Only check the CA and print what would be done incl.\n"+
+		"                           the rendered scripts (secrets masked). Does not\n"+
+		"                           connect to targets and does not request certificates\n"+
+		"\n"+
+		"  -p, --parallel <n>       Number of jobs processed in parallel (default: %d)\n"+
+		"\n"+
+		"  -d, --daemon             Keep running and re-check every job when its\n"+
+		"                           certificate enters the renewal window.\n"+
+		"                           SIGHUP reloads the job configuration.\n"+
+		"\n"+
+		"  --min-interval <dur>     Daemon: minimum time between two checks of a job,\n"+
+		"                           also used as retry delay after a failure (default: %s)\n"+
+		"\n"+
+		"  --max-interval <dur>     Daemon: maximum time between two checks of a job (default: %s)\n"+
+		"\n"+
+		"  --jitter <dur>           Daemon: random delay added to every scheduled check (default: %s)\n"+
+		"                           Durations use the change_after notation, e.g. 1d 2h 30m\n"+
+		"\n"+
+		"  --state <file>           File keeping the results of previous runs\n"+
+		"                           (default: <config path>/%s)\n"+
+		"\n"+
+		"  --report <file|->        Writes a JSON report of the run to a file or to\n"+
+		"                           STDOUT (\"-\"). Exit codes: 0 = all jobs ok,\n"+
+		"                           1 = some jobs failed, 2 = usage error, 3 = config error\n"+
+		"\n"+
+		"  --job <name>             Only process jobs whose name matches, glob patterns\n"+
+		"                           like \"cam*.domain.tld\" allowed, repeatable\n"+
+		"\n"+
+		"  --tag <tag>              Only process jobs with this [job] tag, repeatable\n"+
+		"                           (a job must have all given tags)\n"+
+		"\n"+
+		"  --expiring-within <dur>  Only process jobs whose recorded certificate expires\n"+
+		"                           within the duration, e.g. 14d (jobs without record\n"+
+		"                           are included). Not allowed with --daemon\n"+
+		"\n"+
 		"  -h, --help               Prints this help and exit\n"+
 		"\n"+
 		"  -v, --version            Prints the version and exit\n"+
 		"\n",
 		defaultConfigPath,
+		defaultParallelJobs,
+		defaultMinInterval,
+		defaultMaxInterval,
+		defaultJitter,
+		defaultStateFile,
 	)
 }
 
@@ -101,6 +194,13 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	// options may also follow the command, e.g. "status -c /etc/embed-cert-manager"
+	command := "run"
+	if flag.NArg() > 0 {
+		command = strings.ToLower(flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
 	if versionFlag {
 		fmt.Printf("embed-cert-manager: %s  (c) TS 2026\n", version)
 		os.Exit(0)
@@ -118,108 +218,157 @@ func main() {
 			logger.SetLevel(logger.LevelInfo)
 	}
 
-	runJobs()
+	switch command {
+		case "run":
+			if daemonFlag && !dryRun {
+				// the daemon would force a renewal on every scheduled check
+				if forcePullCert {
+					fmt.Fprintln(os.Stderr, "-f/--force cannot be used with --daemon")
+					os.Exit(exitUsage)
+				}
+				// the selection is made once at (re)load, it would not follow the expiry
+				if expiringWithinRaw != "" {
+					fmt.Fprintln(os.Stderr, "--expiring-within cannot be used with --daemon")
+					os.Exit(exitUsage)
+				}
+				runDaemon()
+			} else {
+				runJobs()
+			}
+		case "status":
+			runStatus(flag.Args())
+		case "revoke":
+			runRevoke(flag.Args())
+		case "check-config", "lint":
+			runCheckConfig()
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+			usage()
+			os.Exit(exitUsage)
+	}
 
 }
 
 /**
  *  runJobs loads configuration/jobs, 
- *  and executes the renewal/update workflow.
+ *  and executes the renewal/update workflow for all jobs 
+ *  using a pool of "parallelJobs" workers.
  * */
 func runJobs() {
 
-	var cfg config.Config
-
-	
-	if cfg.Load(configPath + "/jobs.d") != nil { os.Exit(1) }
-	if len(cfg.Jobs) == 0 {
+	cfg, err := loadConfig()
+	if err != nil { os.Exit(exitConfigError) }
+	if cfg.Jobs, err = selectJobs(cfg.Jobs); err != nil {
+		logger.Errorln(err)
+		os.Exit(exitUsage)
+	}
+	if len(cfg.Jobs) == 0 && len(cfg.Problems) == 0 {
 		logger.Warnln("No jobs to do - exiting"); 
-		os.Exit(0) 
+		os.Exit(exitOK) 
 	}
-	cfg.ConfPath = configPath
 
+	started := time.Now()
+	// a dry-run must not change the recorded state
+	var store *state.Store
+	if !dryRun {
+		store = openStateStore()
+	}
 
+	results := runJobsParallel(cfg.Jobs, parallelJobs, store)
+	results = append(results, configErrorResults(cfg.Problems)...)
+	finishRun(started, results)
 
-	for _, job := range cfg.Jobs {
+	os.Exit(exitCode(results))
 
-		
-		log.Printf("[START] main.c ------ starting job <%s> ------\n",job.Name)
+}
 
-		// 1.) create HTTP client with client certificate and server certificate check
-		httpClient := ejbcaHttpsClient.NewMTLSClient(&job)
-		if httpClient == nil {
-			logger.Errorln("newMTLSClient")
-			continue
-		}
-		// 2.) test connectivity to EJBCA
-		if !ejbcaHttpsClient.TestConnection(&job,httpClient) {
-			logger.Errorln("cant connect to EJBCA %s\n",job.Ca.Host)
-			continue
-		}
+/**
+ *  consoleOut returns the destination of the human readable output (summary, dry-run plan):
+ *  STDERR if the report is written to STDOUT, STDOUT otherwise.
+ * */
+func consoleOut() io.Writer {
+	if reportPath == "-" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
 
-		// 3.) check if the CA has already a certifcate for this host (CN/username)
-		//     if so we do not run this job further
-		logger.Infoln("Check certificate exists");
-		if ! ejbcaHttpsClient.CheckCertState(&job,httpClient) {
-			if !forcePullCert {
-				logger.Infof("------ skipping job <%s>, certificate exists and is valid. ------\n",job.Name)
-				continue
-			} else {
-				logger.Warnf("NOT skipping job <%s>, certificate exists and is valid but forced by CLI \"-f\" parameter\n",job.Name)
-			}
-		}
-		logger.Infoln("need to request certificate");
-
-		// 4.) need to get e.g. CSR from target host
-		logger.Infoln("Runn SSH");
-		certCSR, err :=ssh.RunSSHCommand(job.Name +":" +  strconv.Itoa(job.Target.SSHPort) , job.Target.SSHUser, job.Target.SSHKey, job.GetCSRCmd());
-		if err != nil {
-			logger.Errorf("job <%s> : %v\n",job.Name, err )
-			continue
-		}
 
-		// 5.) Analize CSR
-		logger.Infoln("Parsing CSR");
-		if certCSR.ParseCSRFromString() == nil {
-			logger.Errorln("Parsing CSR output")
-			continue
-		}
-		
-		// 6.) Getting new Ccertificate from CA
-		logger.Infoln("Getting new certificate from CA");
-		cert := ejbcaHttpsClient.EnrollOrRenewCert(&job, httpClient, []byte(certCSR.CertCSR))
-		if cert == nil {
-			logger.Errorln("ejbcaHttpsClient")
-			continue
-		}
+/**
+ *  finishRun prints the summary table and writes the JSON report if requested.
+ *  The summary goes to STDERR if the report is written to STDOUT.
+ *  Params:
+ *    - started: time the run was started.
+ *    - results: job results.
+ * */
+func finishRun(started time.Time, results []jobResult) {
 
+	printSummary(consoleOut(), results)
 
-		// 7.) Convert the cetificate to PEM
-		certBytes, err := ejbcaHttpsClient.CertToPEM(cert)
-		if err != nil {
+	if reportPath != "" {
+		if err := writeReport(reportPath, started, results); err != nil {
 			logger.Errorln(err)
 		}
+	}
+}
 
-		// 7.) assemble ASCII armored (PEM) certificate 
-		job.Target.Certificate = (
-			"Subject: "  + cert.Subject.String() + "\n" +
-			"Issuer: "   + cert.Issuer.String()  + "\n" +
-			"NotAfter: " + cert.NotAfter.Format(time.RFC3339) + "\n" +
-			string(certBytes) +
-			"" )
-
-		// 8.) Connect back to target host to issue cewrtifcate install script from INI file
-		logger.Debugln("setting up SSH command:\n",job.GetCertSetCmd())
-		_, err2 :=ssh.RunSSHCommand(job.Name +":" +  strconv.Itoa(job.Target.SSHPort) , job.Target.SSHUser, job.Target.SSHKey, job.GetCertSetCmd())
-		if err2 != nil {
-			logger.Errorf("job <%s> : %v\n",job.Name, err )
-			continue
-		}
 
-		logger.Infof("------ finalized certifcate update for job <%s> ------\n",job.Name)
+/**
+ *  loadConfig loads all job files from the "jobs.d" folder of the configuration path.
+ *  Returns:
+ *    - *config.Config: loaded configuration.
+ *    - error: non-nil if the job files could not be loaded.
+ * */
+func loadConfig() (*config.Config, error) {
 
+	var cfg config.Config
+
+	if err := cfg.Load(configPath + "/jobs.d"); err != nil {
+		return nil, err
 	}
+	cfg.ConfPath = configPath
 
+	return &cfg, nil
 }
 
 
+/**
+ *  openStateStore opens the state store configured by "--state"
+ *  (default: state.json in the configuration path).
+ *  Returns:
+ *    - *state.Store: opened store, or nil if it cannot be opened (state is then not recorded).
+ * */
+func openStateStore() *state.Store {
+
+	path := stateFile
+	if path == "" {
+		path = filepath.Join(configPath, defaultStateFile)
+	}
+
+	store, err := state.Open(path)
+	if err != nil {
+		logger.Errorf("state store: %v - results are not recorded\n", err)
+		return nil
+	}
+	return store
+}
+
+
+/**
+ *  hostKeyOptions maps the host key related [target] settings of a job
+ *  to the options understood by the ssh package.
+ *
+ *  Params:
+ *    - job: job providing the target configuration.
+ *
+ *  Returns:
+ *    - ssh.HostKeyOptions: host key verification options for the target.
+ * */
+func hostKeyOptions(job *config.Job) ssh.HostKeyOptions {
+	return ssh.HostKeyOptions{
+		Policy:         job.Target.HostKeyCheck,
+		KnownHostsFile: job.Target.KnownHosts,
+		Fingerprint:    job.Target.HostKeyFingerprint,
+		TOFUStateFile:  job.Target.TOFUStateFile,
+	}
+}
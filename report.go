@@ -0,0 +1,170 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file creates the machine-readable JSON run report and
+ *  maps the run outcome to the process exit code.
+ * 
+ * */
+
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+
+// process exit codes
+const (
+	exitOK          = 0 // all jobs skipped or renewed
+	exitJobsFailed  = 1 // at least one job failed
+	exitUsage       = 2 // invalid command line
+	exitConfigError = 3 // configuration or a job file could not be loaded, or a job was refused
+)
+
+
+/**
+ *  reportJob is the report entry of one job.
+ * */
+type reportJob struct {
+	Name 		string 		`json:"name"`
+	Status 		string 		`json:"status"`
+	Phase 		string 		`json:"phase"`
+	Error 		string 		`json:"error,omitempty"`
+	Serial 		string 		`json:"serial,omitempty"`
+	NotAfter 	*time.Time 	`json:"not_after,omitempty"`
+	Fingerprint string 		`json:"fingerprint,omitempty"`
+	RolledBack 	bool 		`json:"rolled_back,omitempty"`
+	DurationMs 	int64 		`json:"duration_ms"`
+}
+
+
+/**
+ *  report is the JSON document written by "--report".
+ * */
+type report struct {
+	Version 	string 		`json:"version"`
+	Started 	time.Time 	`json:"started"`
+	Finished 	time.Time 	`json:"finished"`
+	Total 		int 		`json:"total"`
+	Renewed 	int 		`json:"renewed"`
+	Skipped 	int 		`json:"skipped"`
+	Failed 		int 		`json:"failed"`
+	ConfigErrors int 		`json:"config_errors"`
+	Refused 	int 		`json:"refused"`
+	WouldRenew 	int 		`json:"would_renew"` // dry-run only
+	Jobs 		[]reportJob `json:"jobs"`
+}
+
+
+/**
+ *  buildReport assembles the report document from the job results.
+ *  Params:
+ *    - started: time the run was started.
+ *    - results: job results.
+ *  Returns:
+ *    - report: report document.
+ * */
+func buildReport(started time.Time, results []jobResult) report {
+	r := report{
+		Version:  version,
+		Started:  started,
+		Finished: time.Now(),
+		Total:    len(results),
+		Jobs:     make([]reportJob, 0, len(results)),
+	}
+
+	for _, res := range results {
+		switch res.Status {
+			case statusRenewed:
+				r.Renewed++
+			case statusSkipped:
+				r.Skipped++
+			case statusWouldRenew:
+				r.WouldRenew++
+			case statusFailed:
+				r.Failed++
+			case statusConfigError:
+				r.ConfigErrors++
+			case statusRefused:
+				r.Refused++
+		}
+
+		entry := reportJob{
+			Name:        res.Name,
+			Status:      res.Status,
+			Phase:       res.Phase,
+			Serial:      res.Serial,
+			Fingerprint: res.Fingerprint,
+			RolledBack:  res.RolledBack,
+			DurationMs:  res.Duration.Milliseconds(),
+		}
+		if res.Err != nil {
+			entry.Error = res.Err.Error()
+		}
+		if !res.NotAfter.IsZero() {
+			notAfter := res.NotAfter
+			entry.NotAfter = &notAfter
+		}
+		r.Jobs = append(r.Jobs, entry)
+	}
+	return r
+}
+
+
+/**
+ *  writeReport writes the JSON report to a file, or to STDOUT if path is "-".
+ *  Params:
+ *    - path: destination file or "-".
+ *    - started: time the run was started.
+ *    - results: job results.
+ *  Returns:
+ *    - error: non-nil if the report cannot be written.
+ * */
+func writeReport(path string, started time.Time, results []jobResult) error {
+	data, err := json.MarshalIndent(buildReport(started, results), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write report %q: %w", path, err)
+	}
+	return nil
+}
+
+
+/**
+ *  exitCode returns the process exit code for the given job results.
+ *  Params:
+ *    - results: job results.
+ *  Returns:
+ *    - int: exitConfigError if a job file could not be loaded or a job was refused,
+ *      exitJobsFailed if a job failed, exitOK otherwise.
+ * */
+func exitCode(results []jobResult) int {
+	code := exitOK
+	for _, res := range results {
+		switch res.Status {
+			case statusConfigError, statusRefused:
+				return exitConfigError
+			case statusFailed:
+				code = exitJobsFailed
+		}
+	}
+	return code
+}
@@ -0,0 +1,137 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file implements the "revoke" command, used to revoke the certificates
+ *  of a job at the CA, e.g. when a device was stolen or compromised.
+ * 
+ * */
+
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+
+/**
+ *  runRevoke revokes the current certificate of a job, or with "--all" every certificate
+ *  of its End Entity, and disables the job file if "--disable" is given.
+ *  Params:
+ *    - args: command arguments, the job name followed by optional options.
+ * */
+func runRevoke(args []string) {
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, "revoke: job name missing\n\n")
+		usage()
+		os.Exit(exitUsage)
+	}
+	name := args[0]
+	// options may also follow the job name, e.g. "revoke web.domain.tld --all"
+	flag.CommandLine.Parse(args[1:])
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "revoke: unexpected argument %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	reason, err := config.ParseRevocationReason(revokeReason)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "revoke: %v\n\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// a disabled job may still have a certificate to revoke, e.g. after "revoke --disable"
+	cfg := config.Config{ConfPath: configPath, IncludeDisabled: true}
+	if err := cfg.Load(configPath + "/jobs.d"); err != nil {
+		os.Exit(exitConfigError)
+	}
+
+	// an enabled job is preferred over disabled job files of the same host
+	var job *config.Job
+	for i := range cfg.Jobs {
+		if cfg.Jobs[i].Name == name && (job == nil || !job.Enabled) {
+			job = &cfg.Jobs[i]
+		}
+	}
+	if job == nil {
+		fmt.Fprintf(os.Stderr, "revoke: no job <%s> found in %s/jobs.d\n", name, configPath)
+		os.Exit(exitConfigError)
+	}
+
+	if err := revokeJob(job, reason, revokeAll); err != nil {
+		job.Log().Errorln(err)
+		os.Exit(exitJobsFailed)
+	}
+
+	if revokeDisable && job.HostVars != nil {
+		logger.Warnf("job <%s> is one host of the host list in %s - not disabled, remove the host from the list instead\n", job.Name, job.File)
+	} else if revokeDisable && !job.Enabled {
+		fmt.Printf("job <%s> already disabled in %s\n", job.Name, job.File)
+	} else if revokeDisable {
+		if err := config.DisableJobFile(job.File); err != nil {
+			logger.Errorf("disable job <%s>: %v\n", job.Name, err)
+			os.Exit(exitJobsFailed)
+		}
+		fmt.Printf("job <%s> disabled in %s\n", job.Name, job.File)
+	}
+	os.Exit(exitOK)
+}
+
+
+/**
+ *  revokeJob revokes the certificate(s) of a job at the CA.
+ *  Params:
+ *    - job: job whose certificates are revoked.
+ *    - reason: RFC 5280 revocation reason code.
+ *    - all: revoke every certificate of the End Entity instead of only the current one.
+ *  Returns:
+ *    - error: non-nil if the CA cannot be reached, no certificate was found or revocation failed.
+ * */
+func revokeJob(job *config.Job, reason int32, all bool) error {
+
+	defer ejbcaHttpsClient.CancelStoredContext(job.Name)
+
+	httpClient := ejbcaHttpsClient.NewMTLSClient(job)
+	if httpClient == nil {
+		return fmt.Errorf("newMTLSClient failed")
+	}
+	ctx := ejbcaHttpsClient.GetContext(job.Name)
+
+	if all {
+		if err := ejbcaHttpsClient.RevokeUserViaGowsdl(ctx, job, httpClient, reason); err != nil {
+			return err
+		}
+		fmt.Printf("revoked all certificates of <%s> (reason %s)\n", job.Name, config.RevocationReasonName(reason))
+		return nil
+	}
+
+	certs, err := ejbcaHttpsClient.FindCertsViaGowsdl(ctx, job, httpClient, true)
+	if err != nil {
+		return err
+	}
+	cert := ejbcaHttpsClient.PickBestValidCert(time.Now(), certs)
+	if cert == nil {
+		return fmt.Errorf("no valid certificate of <%s> found on the CA", job.Name)
+	}
+
+	if err := ejbcaHttpsClient.RevokeCertViaGowsdl(ctx, job, httpClient, cert, reason); err != nil {
+		return err
+	}
+	fmt.Printf("revoked certificate of <%s> serial %s (reason %s)\n",
+		job.Name, ejbcaHttpsClient.CertSerial(cert), config.RevocationReasonName(reason))
+	return nil
+}
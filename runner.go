@@ -0,0 +1,406 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file contains the workflow of a single job and the bounded
+ *  worker pool which executes several jobs in parallel.
+ * 
+ * */
+
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ssh"
+	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
+	"github.com/tseiman/embed-cert-manager/logger"
+	"github.com/tseiman/embed-cert-manager/state"
+)
+
+
+const (
+	statusSkipped = "skipped"
+	statusRenewed = "renewed"
+	statusFailed  = "failed"
+	statusWouldRenew = "would-renew" // dry-run only
+	statusConfigError = "config-error" // job file could not be loaded
+	statusRefused = "refused" // job not run because of configuration problems, see check-config
+)
+
+// phases of a job run, the last one entered is reported as "phase reached"
+const (
+	phaseConfig  = "config"
+	phaseConnect = "connect"
+	phaseCheck   = "check"
+	phaseCSR     = "csr"
+	phaseEnroll  = "enroll"
+	phaseInstall = "install"
+	phaseVerify  = "verify"
+	phaseDone    = "done"
+)
+
+/**
+ *  jobResult holds the outcome of one job run.
+ * */
+type jobResult struct {
+	Name 		string
+	Status 		string
+	Phase 		string
+	Err 		error
+	Duration 	time.Duration
+	NotAfter 	time.Time 		// NotAfter of the certificate valid after the run (zero if unknown)
+	Serial 		string 			// serial of the certificate valid after the run
+	Fingerprint string 			// SHA-256 fingerprint of the certificate valid after the run
+	RolledBack 	bool 			// true if a failed install was rolled back on the target
+}
+
+
+/**
+ *  runJobsParallel executes all jobs with at most "workers" jobs running at the same time.
+ *  The outcome of each job is recorded in the state store as soon as the job is done.
+ *  Params:
+ *    - jobs: jobs to execute.
+ *    - workers: size of the worker pool, values < 1 are treated as 1.
+ *    - store: state store to record results in (may be nil).
+ *  Returns:
+ *    - []jobResult: one result per job, in the order of the jobs slice.
+ * */
+func runJobsParallel(jobs []config.Job, workers int, store *state.Store) []jobResult {
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	results := make([]jobResult, len(jobs))
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i] = runJob(&jobs[i])
+				recordState(store, results[i])
+			}
+		}()
+	}
+
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+
+/**
+ *  runJob executes the renewal/update workflow for a single job.
+ *  Params:
+ *    - job: job to execute.
+ *  Returns:
+ *    - jobResult: outcome of the job.
+ * */
+func runJob(job *config.Job) (res jobResult) {
+
+	log := job.Log()
+	start := time.Now()
+	res = jobResult{Name: job.Name, Status: statusFailed, Phase: phaseConfig}
+
+	defer func() {
+		res.Duration = time.Since(start)
+		ejbcaHttpsClient.CancelStoredContext(job.Name)
+		job.ForgetPrivateKey()
+	}()
+
+	fail := func(err error) jobResult {
+		log.Errorln(err)
+		res.Err = err
+		return res
+	}
+
+	log.Infof("------ starting job <%s> ------\n",job.Name)
+
+	// 0.) refuse jobs with configuration problems, see "check-config"
+	if problems := checkJob(job); len(problems) > 0 {
+		for _, p := range problems {
+			log.Errorln(p.String())
+		}
+		res.Status = statusRefused
+		return fail(fmt.Errorf("configuration invalid: %d problem(s), see check-config", len(problems)))
+	}
+	res.Phase = phaseConnect
+
+	// 1.) create HTTP client with client certificate and server certificate check
+	httpClient := ejbcaHttpsClient.NewMTLSClient(job)
+	if httpClient == nil {
+		return fail(fmt.Errorf("newMTLSClient failed"))
+	}
+	// 2.) test connectivity to EJBCA
+	if !ejbcaHttpsClient.TestConnection(job,httpClient) {
+		return fail(fmt.Errorf("cant connect to EJBCA %s",job.Ca.Host))
+	}
+
+	// 3.) check if the CA has already a certifcate for this host (CN/username)
+	//     if so we do not run this job further
+	res.Phase = phaseCheck
+	log.Infoln("Check certificate exists");
+	certState, err := ejbcaHttpsClient.CertState(job,httpClient)
+	if err != nil {
+		return fail(fmt.Errorf("check certificate at CA: %w", err))
+	}
+	certState = checkDeployed(job, certState)
+	if current := certState.Current; current != nil {
+		res.NotAfter = current.NotAfter
+		res.Serial = ejbcaHttpsClient.CertSerial(current)
+		res.Fingerprint = ejbcaHttpsClient.CertFingerprint(current)
+	}
+	if dryRun {
+		res.Status = printPlan(consoleOut(), job, certState)
+		res.Phase = phaseDone
+		return res
+	}
+	if !certState.Renew {
+		if !forcePullCert {
+			log.Infof("------ skipping job <%s>, certificate exists and is valid. ------\n",job.Name)
+			res.Status = statusSkipped
+			res.Phase = phaseDone
+			return res
+		} else {
+			log.Warnf("NOT skipping job <%s>, certificate exists and is valid but forced by CLI \"-f\" parameter\n",job.Name)
+		}
+	}
+	log.Infoln("need to request certificate");
+
+	// 4.) Backup the current certificate material on the target host, before the
+	//     CSR step which may replace the key
+	res.Phase = phaseCSR
+	if job.Target.Backup {
+		log.Infoln("Backup current certificate and key on target");
+		if _, err := ssh.RunSSHCommand(sshAddr(job), job.Target.SSHUser, job.Target.SSHKey, hostKeyOptions(job), job.GetBackupCmd()); err != nil {
+			return fail(fmt.Errorf("backup on target via SSH: %w", err))
+		}
+	}
+
+	// once the target is changed (csr_command, install) a failure restores the backup
+	targetChanged := false
+	failRollback := func(err error) jobResult {
+		if targetChanged {
+			res.RolledBack = rollbackInstall(job)
+		}
+		return fail(err)
+	}
+
+	// 5.) need to get e.g. CSR from target host, or create key and CSR locally
+	var csrPEM string
+	if localKeySource(job) {
+		log.Infoln("Creating key and CSR locally");
+		var err error
+		csrPEM, err = createLocalCSR(job)
+		if err != nil {
+			return fail(fmt.Errorf("create local key/CSR: %w", err))
+		}
+	} else {
+		log.Infoln("Runn SSH");
+		targetChanged = true
+		certCSR, err :=ssh.RunSSHCommand(sshAddr(job), job.Target.SSHUser, job.Target.SSHKey, hostKeyOptions(job), job.GetCSRCmd());
+		if err != nil {
+			return failRollback(fmt.Errorf("get CSR via SSH: %w", err))
+		}
+
+		// 5a.) Analize CSR
+		log.Infoln("Parsing CSR");
+		if certCSR.ParseCSRFromString() == nil {
+			return failRollback(fmt.Errorf("parsing CSR output failed"))
+		}
+		csrPEM = certCSR.CertCSR
+
+		// 5b.) Check the CSR requests only what the job declares
+		if err := job.CheckCSRPolicy(csrPEM); err != nil {
+			switch job.CSRPolicy() {
+				case config.CSRPolicyOff:
+				case config.CSRPolicyWarn:
+					log.Warnln(err)
+				default:
+					return failRollback(err)
+			}
+		}
+	}
+	
+	// 6.) Getting new Ccertificate from CA
+	res.Phase = phaseEnroll
+	log.Infoln("Getting new certificate from CA");
+	cert := ejbcaHttpsClient.EnrollOrRenewCert(job, httpClient, []byte(csrPEM))
+	if cert == nil {
+		return failRollback(fmt.Errorf("enrollment at EJBCA failed"))
+	}
+	res.NotAfter = cert.NotAfter
+	res.Serial = ejbcaHttpsClient.CertSerial(cert)
+	res.Fingerprint = ejbcaHttpsClient.CertFingerprint(cert)
+
+	// 6b.) Get the current CA chain of the new certificate if configured
+	if job.FetchCAChain() {
+		log.Infoln("Getting CA chain from CA");
+		if err := updateCAChain(job, httpClient, cert); err != nil {
+			return failRollback(err)
+		}
+	}
+
+	// 7.) Convert the cetificate to PEM
+	certBytes, err := ejbcaHttpsClient.CertToPEM(cert)
+	if err != nil {
+		return failRollback(fmt.Errorf("encode certificate: %w", err))
+	}
+
+	// 7.) assemble ASCII armored (PEM) certificate 
+	job.Target.Certificate = (
+		"Subject: "  + cert.Subject.String() + "\n" +
+		"Issuer: "   + cert.Issuer.String()  + "\n" +
+		"NotAfter: " + cert.NotAfter.Format(time.RFC3339) + "\n" +
+		string(certBytes) +
+		"" )
+
+	// 8.) Write certificate files natively (SFTP / STDIN) if configured
+	res.Phase = phaseInstall
+	targetChanged = true
+	if nativeDelivery(job) {
+		log.Infof("Delivering certificate files (%s)\n", job.Target.Delivery)
+		if err := deliverCertificate(job, certBytes); err != nil {
+			return failRollback(fmt.Errorf("deliver certificate: %w", err))
+		}
+	}
+
+	// 9.) Connect back to target host to issue cewrtifcate install script from INI file
+	//     (with native delivery this is typically only the service reload)
+	if strings.TrimSpace(job.Target.SetCertCommand) != "" {
+		log.Debugln("setting up SSH command:\n",job.GetCertSetCmd())
+		_, err =ssh.RunSSHCommand(sshAddr(job), job.Target.SSHUser, job.Target.SSHKey, hostKeyOptions(job), job.GetCertSetCmd())
+		if err != nil {
+			return failRollback(fmt.Errorf("install certificate via SSH: %w", err))
+		}
+	}
+
+	// 10.) Verify the service on the target presents the new certificate
+	res.Phase = phaseVerify
+	if err := verifyInstall(job, cert); err != nil {
+		return failRollback(err)
+	}
+
+	// 11.) Revoke the certificates replaced by this one if configured
+	//      the new certificate is in use, so a failure here does not fail the job
+	if job.Ca.RevokeSuperseded {
+		log.Infoln("Revoking superseded certificates");
+		if _, err := ejbcaHttpsClient.RevokeSuperseded(job, httpClient, cert); err != nil {
+			log.Errorf("revoking superseded certificates: %v\n", err)
+		}
+	}
+
+	log.Infof("------ finalized certifcate update for job <%s> ------\n",job.Name)
+	res.Status = statusRenewed
+	res.Phase = phaseDone
+	return res
+}
+
+
+/**
+ *  rollbackInstall restores the previous certificate material on the target after a
+ *  failed install or verification, if rollback is enabled for the job.
+ *  Params:
+ *    - job: job whose install failed.
+ *  Returns:
+ *    - bool: true if the rollback was executed successfully.
+ * */
+func rollbackInstall(job *config.Job) bool {
+
+	if !job.RollbackEnabled() {
+		return false
+	}
+
+	log := job.Log()
+	log.Warnln("rolling back certificate install on target")
+
+	_, err := ssh.RunSSHCommand(sshAddr(job), job.Target.SSHUser, job.Target.SSHKey, hostKeyOptions(job), job.GetRollbackCmd())
+	if err != nil {
+		log.Errorf("rollback failed: %v\n", err)
+		return false
+	}
+
+	log.Warnln("rollback done, previous certificate restored")
+	return true
+}
+
+
+/**
+ *  recordState stores the outcome of a job run in the state store.
+ *  Params:
+ *    - store: state store (nothing is recorded if nil).
+ *    - res: result of the job run.
+ * */
+func recordState(store *state.Store, res jobResult) {
+	if store == nil {
+		return
+	}
+
+	now := time.Now()
+	err := store.Update(res.Name, func(js *state.JobState) {
+		js.LastAttempt = now
+		js.LastStatus = res.Status
+		if res.Status == statusFailed || res.Status == statusRefused {
+			js.Failures++
+			js.TotalFailures++
+			js.LastError = res.Err.Error()
+			return
+		}
+		js.LastSuccess = now
+		js.Failures = 0
+		js.LastError = ""
+		if res.Status == statusRenewed {
+			js.LastRenewal = now
+		}
+		if res.Serial != "" {
+			js.Serial = res.Serial
+			js.NotAfter = res.NotAfter
+			js.Fingerprint = res.Fingerprint
+		}
+	})
+	if err != nil {
+		logger.Errorf("recording state of job <%s>: %v\n", res.Name, err)
+	}
+}
+
+
+/**
+ *  printSummary writes a table with the outcome of every job.
+ *  Params:
+ *    - w: destination of the table.
+ *    - results: job results to print.
+ * */
+func printSummary(w io.Writer, results []jobResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tSTATUS\tDURATION\tERROR")
+	for _, r := range results {
+		errText := "-"
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Name, r.Status, r.Duration.Round(time.Millisecond), errText)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,147 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  This file implements the job selection on the command line by
+ *  name ("--job"), tag ("--tag") and certificate expiry ("--expiring-within").
+ *
+ * */
+
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/logger"
+	"github.com/tseiman/embed-cert-manager/state"
+)
+
+
+/**
+ *  stringList is a repeatable string flag, e.g. "--job a --job b".
+ * */
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+
+/**
+ *  jobFilterActive reports whether any job selection flag is set.
+ * */
+func jobFilterActive() bool {
+	return len(jobNames) > 0 || len(jobTags) > 0 || expiringWithinRaw != ""
+}
+
+
+/**
+ *  selectJobs returns the jobs matching the selection flags:
+ *    - "--job": name matches one of the glob patterns (case-insensitive),
+ *    - "--tag": job has all of the given tags,
+ *    - "--expiring-within": recorded certificate expires within the duration,
+ *      jobs without recorded certificate are selected as well.
+ *  Params:
+ *    - jobs: loaded jobs.
+ *  Returns:
+ *    - []config.Job: selected jobs, all jobs if no selection flag is set.
+ *    - error: non-nil if a flag value is invalid or the state cannot be read.
+ * */
+func selectJobs(jobs []config.Job) ([]config.Job, error) {
+
+	if !jobFilterActive() {
+		return jobs, nil
+	}
+
+	for _, p := range jobNames {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("--job %q: %w", p, err)
+		}
+	}
+
+	var expiring time.Duration
+	var store *state.Store
+	if expiringWithinRaw != "" {
+		expiring = time.Duration(config.ParseEJBCAValidity(expiringWithinRaw)) * time.Second
+		if expiring <= 0 {
+			return nil, fmt.Errorf("--expiring-within %q: invalid duration", expiringWithinRaw)
+		}
+		if store = openStateStore(); store == nil {
+			return nil, fmt.Errorf("--expiring-within: job state cannot be read")
+		}
+	}
+
+	var selected []config.Job
+	for _, job := range jobs {
+		if !matchJobName(job.Name) {
+			continue
+		}
+		if !matchJobTags(&job) {
+			continue
+		}
+		if store != nil && !expiresWithin(&job, store, expiring) {
+			continue
+		}
+		selected = append(selected, job)
+	}
+
+	logger.Infof("%d of %d job(s) selected\n", len(selected), len(jobs))
+	return selected, nil
+}
+
+
+/**
+ *  matchJobName reports whether a job name matches one of the "--job" patterns.
+ * */
+func matchJobName(name string) bool {
+	if len(jobNames) == 0 {
+		return true
+	}
+	for _, p := range jobNames {
+		if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+
+/**
+ *  matchJobTags reports whether a job has all "--tag" tags.
+ * */
+func matchJobTags(job *config.Job) bool {
+	for _, t := range jobTags {
+		if !job.HasTag(t) {
+			return false
+		}
+	}
+	return true
+}
+
+
+/**
+ *  expiresWithin reports whether the certificate recorded for a job expires within d.
+ *  Jobs without recorded certificate are selected, their expiry is unknown.
+ * */
+func expiresWithin(job *config.Job, store *state.Store, d time.Duration) bool {
+	js, ok := store.Get(job.Name)
+	if !ok || js.NotAfter.IsZero() {
+		job.Log().Infoln("no certificate recorded in the state - selected by --expiring-within")
+		return true
+	}
+	return time.Until(js.NotAfter) <= d
+}
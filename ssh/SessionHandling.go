@@ -9,7 +9,7 @@ package ssh
  *  with limited software capabilities.
  * 
  *  Package ssh contains helpers for parsing command output returned
- *  from remote SSH executions.
+ *  from remote SSH executions (CSRs and certificates).
  *
  */
 
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 
 	"github.com/tseiman/embed-cert-manager/logger"
 )
@@ -92,3 +93,31 @@ func (s *SessionReturn) ParseCSRFromString() *string {
 	logger.Errorln("no valid CSR found in stdout")
 	return nil
 }
+
+
+/**
+ *  ParseCertificateFromString extracts the first PEM-encoded certificate from the session output.
+ *  Text around the PEM block (e.g. "Subject: ..." lines) is ignored.
+ *
+ *  Returns:
+ *    - *x509.Certificate: parsed certificate.
+ *    - error: non-nil if no valid certificate was found.
+ *
+ */
+func (s *SessionReturn) ParseCertificateFromString() (*x509.Certificate, error) {
+	rest := s.StdOut.Bytes()
+
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		return x509.ParseCertificate(block.Bytes)
+	}
+
+	return nil, fmt.Errorf("no PEM certificate found in stdout")
+}
@@ -15,37 +15,32 @@ package ssh
  */
 
 import (
+	"crypto/x509"
+	"fmt"
 	"os"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/tseiman/embed-cert-manager/config"
+
 	"github.com/tseiman/embed-cert-manager/logger"
 )
 
 
 /**
- *  RunSSHCommand connects to a target via SSH and executes a shell command.
- *  It captures STDOUT and STDERR and returns them as a SessionReturn.
+ *  dial opens an authenticated SSH connection to a target.
  *
  *  Params:
  *    - addr: target address in host:port form.
  *    - user: SSH username.
  *    - keyPath: path to the private SSH key.
- *    - cmd: shell command to execute remotely.
+ *    - hostKey: host key verification policy for the target.
  *
  *  Returns:
- *    - *SessionReturn: captured session output.
- *    - error: non-nil if connection or execution fails.
+ *    - *ssh.Client: connected client, to be closed by the caller.
+ *    - error: non-nil if the key cannot be read or the connection fails.
  *
  */
-func RunSSHCommand(addr, user, keyPath, cmd string) (*SessionReturn, error) {
-
-	var sessionRet SessionReturn
-
-	logger.Debugf("Connecting via SSH to:\n")
-	logger.Debugf("   Address: %s\n",addr)
-	logger.Debugf("   User: %s\n",user)
-	logger.Debugf("   keyPath: %s\n",keyPath)
-	logger.Debugf("   cmd: \n%s\n", cmd)
+func dial(addr, user, keyPath string, hostKey HostKeyOptions) (*ssh.Client, error) {
 
 	if _, err := os.Stat(keyPath); err != nil {
 		return nil, err
@@ -55,16 +50,57 @@ func RunSSHCommand(addr, user, keyPath, cmd string) (*SessionReturn, error) {
 	key, _ := os.ReadFile(keyPath)
 	signer, _ := ssh.ParsePrivateKey(key)
 
+	hostKeyCallback, err := hostKey.HostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
 	config := &ssh.ClientConfig{
 		User: user,
 		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 	}
 
 	client, err := ssh.Dial("tcp", addr, config)
 	if err != nil {
 		return nil, err
 	}
+	return client, nil
+}
+
+
+/**
+ *  RunSSHCommand connects to a target via SSH and executes a shell command.
+ *  It captures STDOUT and STDERR and returns them as a SessionReturn.
+ *
+ *  Params:
+ *    - addr: target address in host:port form.
+ *    - user: SSH username.
+ *    - keyPath: path to the private SSH key.
+ *    - hostKey: host key verification policy for the target.
+ *    - cmd: shell command to execute remotely.
+ *
+ *  Returns:
+ *    - *SessionReturn: captured session output.
+ *    - error: non-nil if connection or execution fails.
+ *
+ */
+func RunSSHCommand(addr, user, keyPath string, hostKey HostKeyOptions, cmd string) (*SessionReturn, error) {
+
+	var sessionRet SessionReturn
+	log := logger.WithPrefix(addr)
+
+	log.Debugf("Connecting via SSH to:\n")
+	log.Debugf("   Address: %s\n",addr)
+	log.Debugf("   User: %s\n",user)
+	log.Debugf("   keyPath: %s\n",keyPath)
+	log.Debugf("   hostKeyCheck: %s\n",hostKey.EffectivePolicy())
+	log.Debugf("   cmd: \n%s\n", cmd)
+
+	client, err := dial(addr, user, keyPath, hostKey)
+	if err != nil {
+		return nil, err
+	}
 	defer client.Close()
 
 	session, err := client.NewSession()
@@ -81,14 +117,45 @@ func RunSSHCommand(addr, user, keyPath, cmd string) (*SessionReturn, error) {
 
 
 
-	logger.Debugf("\n",session.Stdout)
-	logger.Debugf("\n",session.Stderr)
+	log.Debugf("\n%s",&sessionRet.StdOut)
+	log.Debugf("\n%s",&sessionRet.StdErr)
 
 	if err != nil {
-		logger.Errorf("SSH: %v",err)
+		log.Errorf("SSH: %v",err)
 		return nil, err
 	}
 
 
 	return &sessionRet, nil
 }
+
+
+/**
+ *  FetchRemoteCertificate reads a PEM certificate file from the target via SSH.
+ *  If the file contains a chain, the first certificate (the leaf) is returned.
+ *
+ *  Params:
+ *    - addr: target address in host:port form.
+ *    - user: SSH username.
+ *    - keyPath: path to the private SSH key.
+ *    - hostKey: host key verification policy for the target.
+ *    - certPath: path of the certificate file on the target.
+ *
+ *  Returns:
+ *    - *x509.Certificate: deployed certificate.
+ *    - error: non-nil if the file cannot be read or contains no certificate.
+ *
+ */
+func FetchRemoteCertificate(addr, user, keyPath string, hostKey HostKeyOptions, certPath string) (*x509.Certificate, error) {
+
+	ret, err := RunSSHCommand(addr, user, keyPath, hostKey, "cat -- " + config.ShellQuote(certPath))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", certPath, err)
+	}
+
+	cert, err := ret.ParseCertificateFromString()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", certPath, err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,240 @@
+package ssh
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package ssh implements native file delivery to target systems. Files are written
+ *  to a temporary name next to the destination and renamed into place, so a target
+ *  never sees a half-written certificate or key. SFTP is used if the target offers it,
+ *  otherwise the data is streamed via STDIN into "cat".
+ *
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+const (
+	DeliverySFTP   = "sftp"
+	DeliveryStdin  = "stdin"
+	DeliveryNative = "native" // SFTP with fallback to STDIN
+
+	tmpSuffix = ".ecm-tmp"
+)
+
+/**
+ *  RemoteFile describes one file to be written on the target.
+ *  Owner and Group are optional user/group names (or numeric ids) applied with chown.
+ *
+ */
+type RemoteFile struct {
+	Path  string
+	Data  []byte
+	Mode  os.FileMode
+	Owner string
+	Group string
+}
+
+/**
+ *  DeliverFiles writes files atomically to the target using a single SSH connection.
+ *
+ *  Params:
+ *    - addr: target address in host:port form.
+ *    - user: SSH username.
+ *    - keyPath: path to the private SSH key.
+ *    - hostKey: host key verification policy for the target.
+ *    - method: "sftp", "stdin" or "native" (SFTP, falling back to STDIN if SFTP is not available).
+ *    - files: files to write.
+ *
+ *  Returns:
+ *    - error: non-nil if a file could not be written.
+ *
+ */
+func DeliverFiles(addr, user, keyPath string, hostKey HostKeyOptions, method string, files []RemoteFile) error {
+
+	log := logger.WithPrefix(addr)
+
+	client, err := dial(addr, user, keyPath, hostKey)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	method = strings.ToLower(strings.TrimSpace(method))
+	switch method {
+	case DeliverySFTP, DeliveryNative:
+		sc, err := sftp.NewClient(client)
+		if err != nil {
+			if method == DeliverySFTP {
+				return fmt.Errorf("SFTP: %w", err)
+			}
+			log.Warnf("SFTP not available (%v) - falling back to STDIN delivery\n", err)
+			break
+		}
+		defer sc.Close()
+
+		for _, f := range files {
+			if err := writeFileSFTP(client, sc, f); err != nil {
+				return err
+			}
+			log.Infof("delivered %s via SFTP\n", f.Path)
+		}
+		return nil
+
+	case DeliveryStdin:
+
+	default:
+		return fmt.Errorf("unknown delivery method %q (allowed: %s, %s, %s)", method, DeliveryNative, DeliverySFTP, DeliveryStdin)
+	}
+
+	for _, f := range files {
+		if err := writeFileStdin(client, f); err != nil {
+			return err
+		}
+		log.Infof("delivered %s via STDIN\n", f.Path)
+	}
+	return nil
+}
+
+/**
+ *  writeFileSFTP uploads a file to a temporary name, applies mode/ownership
+ *  and renames it into place.
+ *
+ *  Params:
+ *    - client: connected SSH client.
+ *    - sc: SFTP client on top of the SSH connection.
+ *    - f: file to write.
+ *
+ *  Returns:
+ *    - error: non-nil if any step fails; the temporary file is removed in that case.
+ *
+ */
+func writeFileSFTP(client *ssh.Client, sc *sftp.Client, f RemoteFile) (err error) {
+	tmp := f.Path + tmpSuffix
+
+	defer func() {
+		if err != nil {
+			sc.Remove(tmp)
+		}
+	}()
+
+	dst, err := sc.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("SFTP create %s: %w", tmp, err)
+	}
+	if err := dst.Chmod(f.Mode); err != nil {
+		dst.Close()
+		return fmt.Errorf("SFTP chmod %s: %w", tmp, err)
+	}
+	if _, err := dst.Write(f.Data); err != nil {
+		dst.Close()
+		return fmt.Errorf("SFTP write %s: %w", tmp, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("SFTP close %s: %w", tmp, err)
+	}
+
+	if chown := chownCmd(f, tmp); chown != "" {
+		if err := runSession(client, chown, nil); err != nil {
+			return fmt.Errorf("chown %s: %w", tmp, err)
+		}
+	}
+
+	if err := sc.PosixRename(tmp, f.Path); err != nil {
+		// server without posix-rename extension
+		if err := runSession(client, "mv -f -- "+config.ShellQuote(tmp)+" "+config.ShellQuote(f.Path), nil); err != nil {
+			return fmt.Errorf("rename %s: %w", tmp, err)
+		}
+	}
+	return nil
+}
+
+/**
+ *  writeFileStdin streams a file via STDIN into "cat" on the target, applies
+ *  mode/ownership and renames it into place in one shell command.
+ *
+ *  Params:
+ *    - client: connected SSH client.
+ *    - f: file to write.
+ *
+ *  Returns:
+ *    - error: non-nil if the remote command fails.
+ *
+ */
+func writeFileStdin(client *ssh.Client, f RemoteFile) error {
+	tmp := config.ShellQuote(f.Path + tmpSuffix)
+
+	cmd := "umask 077 && cat > " + tmp +
+		" && chmod " + fmt.Sprintf("%04o", f.Mode.Perm()) + " " + tmp
+	if chown := chownCmd(f, f.Path+tmpSuffix); chown != "" {
+		cmd += " && " + chown
+	}
+	cmd += " && mv -f -- " + tmp + " " + config.ShellQuote(f.Path) +
+		" || { rm -f -- " + tmp + "; exit 1; }"
+
+	if err := runSession(client, cmd, f.Data); err != nil {
+		return fmt.Errorf("write %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+/**
+ *  chownCmd returns the chown command applying owner/group of f to path,
+ *  or an empty string if neither is configured.
+ *
+ */
+func chownCmd(f RemoteFile, path string) string {
+	spec := f.Owner
+	if f.Group != "" {
+		spec += ":" + f.Group
+	}
+	if spec == "" {
+		return ""
+	}
+	return "chown -- " + config.ShellQuote(spec) + " " + config.ShellQuote(path)
+}
+
+/**
+ *  runSession runs one command in a new session of an existing connection.
+ *
+ *  Params:
+ *    - client: connected SSH client.
+ *    - cmd: command to run.
+ *    - stdin: data fed to STDIN of the command (may be nil).
+ *
+ *  Returns:
+ *    - error: non-nil if the command fails; STDERR of the command is included.
+ *
+ */
+func runSession(client *ssh.Client, cmd string, stdin []byte) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package ssh
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package ssh implements the host key verification policies used when
+ *  connecting to target systems (known_hosts, pinned fingerprint, trust-on-first-use).
+ *
+ */
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+const (
+	HostKeyInsecure    = "insecure"
+	HostKeyKnownHosts  = "known_hosts"
+	HostKeyFingerprint = "fingerprint"
+	HostKeyTOFU        = "tofu"
+)
+
+/**
+ *  HostKeyOptions selects how the host key of a target is verified.
+ *  Policy is one of "insecure", "known_hosts", "fingerprint" or "tofu". If Policy is empty
+ *  it is derived from the other fields (fingerprint before known_hosts, otherwise insecure).
+ *
+ */
+type HostKeyOptions struct {
+	Policy         string
+	KnownHostsFile string
+	Fingerprint    string
+	TOFUStateFile  string
+}
+
+// serializes read-modify-write access to TOFU state files
+var tofuMu sync.Mutex
+
+/**
+ *  EffectivePolicy returns the policy that will be applied for these options.
+ *
+ *  Returns:
+ *    - string: one of the HostKey* policy names.
+ *
+ */
+func (o HostKeyOptions) EffectivePolicy() string {
+	p := strings.ToLower(strings.TrimSpace(o.Policy))
+	if p != "" {
+		return p
+	}
+	if o.Fingerprint != "" {
+		return HostKeyFingerprint
+	}
+	if o.KnownHostsFile != "" {
+		return HostKeyKnownHosts
+	}
+	return HostKeyInsecure
+}
+
+/**
+ *  HostKeyCallback builds the ssh.HostKeyCallback for the configured policy.
+ *
+ *  Returns:
+ *    - ssh.HostKeyCallback: callback to be used in ssh.ClientConfig.
+ *    - error: non-nil if the policy is unknown or its parameters are incomplete.
+ *
+ */
+func (o HostKeyOptions) HostKeyCallback() (ssh.HostKeyCallback, error) {
+	switch o.EffectivePolicy() {
+	case HostKeyInsecure:
+		logger.Warnln("SSH host key verification disabled (host_key_check = insecure)")
+		return ssh.InsecureIgnoreHostKey(), nil
+
+	case HostKeyKnownHosts:
+		if o.KnownHostsFile == "" {
+			return nil, fmt.Errorf("host key policy %q requires 'known_hosts' to be set", HostKeyKnownHosts)
+		}
+		cb, err := knownhosts.New(expandHome(o.KnownHostsFile))
+		if err != nil {
+			return nil, fmt.Errorf("load known_hosts %q: %w", o.KnownHostsFile, err)
+		}
+		return cb, nil
+
+	case HostKeyFingerprint:
+		if o.Fingerprint == "" {
+			return nil, fmt.Errorf("host key policy %q requires 'host_key_fingerprint' to be set", HostKeyFingerprint)
+		}
+		want := normalizeFingerprint(o.Fingerprint)
+		return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			got := ssh.FingerprintSHA256(key)
+			if got != want {
+				return fmt.Errorf("host key mismatch for %s: got %s, pinned %s", hostname, got, want)
+			}
+			return nil
+		}, nil
+
+	case HostKeyTOFU:
+		if o.TOFUStateFile == "" {
+			return nil, fmt.Errorf("host key policy %q requires a TOFU state file", HostKeyTOFU)
+		}
+		return tofuCallback(expandHome(o.TOFUStateFile)), nil
+	}
+
+	return nil, fmt.Errorf("unknown host key policy %q (allowed: %s, %s, %s, %s)",
+		o.Policy, HostKeyInsecure, HostKeyKnownHosts, HostKeyFingerprint, HostKeyTOFU)
+}
+
+/**
+ *  tofuCallback returns a callback which accepts and records the host key on first contact
+ *  and afterwards only accepts the recorded key. The state file uses known_hosts format.
+ *
+ *  Params:
+ *    - stateFile: path of the known_hosts style file holding the recorded keys.
+ *
+ *  Returns:
+ *    - ssh.HostKeyCallback: TOFU verification callback.
+ *
+ */
+func tofuCallback(stateFile string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		tofuMu.Lock()
+		defer tofuMu.Unlock()
+
+		if _, err := os.Stat(stateFile); err == nil {
+			cb, err := knownhosts.New(stateFile)
+			if err != nil {
+				return fmt.Errorf("load TOFU state %q: %w", stateFile, err)
+			}
+			err = cb(hostname, remote, key)
+			if err == nil {
+				return nil
+			}
+			var keyErr *knownhosts.KeyError
+			if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+				return fmt.Errorf("host key mismatch for %s (TOFU state %s): %w", hostname, stateFile, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("stat TOFU state %q: %w", stateFile, err)
+		}
+
+		// first contact -> record key
+		if err := os.MkdirAll(filepath.Dir(stateFile), 0o700); err != nil {
+			return fmt.Errorf("create TOFU state dir: %w", err)
+		}
+		f, err := os.OpenFile(stateFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("open TOFU state %q: %w", stateFile, err)
+		}
+		defer f.Close()
+
+		line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("write TOFU state %q: %w", stateFile, err)
+		}
+		logger.Warnf("TOFU: recorded new host key for %s (%s) in %s\n", hostname, ssh.FingerprintSHA256(key), stateFile)
+		return nil
+	}
+}
+
+/**
+ *  normalizeFingerprint brings a configured SHA256 fingerprint into the form
+ *  returned by ssh.FingerprintSHA256 ("SHA256:<base64 without padding>").
+ *
+ *  Params:
+ *    - fp: configured fingerprint with or without "SHA256:" prefix.
+ *
+ *  Returns:
+ *    - string: normalized fingerprint.
+ *
+ */
+func normalizeFingerprint(fp string) string {
+	fp = strings.TrimSpace(fp)
+	if len(fp) > 7 && strings.EqualFold(fp[:7], "SHA256:") {
+		fp = fp[7:]
+	}
+	return "SHA256:" + strings.TrimRight(fp, "=")
+}
+
+/**
+ *  expandHome replaces a leading "~/" with the home directory of the current user.
+ *
+ *  Params:
+ *    - path: path possibly starting with "~/".
+ *
+ *  Returns:
+ *    - string: expanded path.
+ *
+ */
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,182 @@
+package state
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package state implements a small persistent JSON store which remembers
+ *  the outcome of previous job runs (last attempt, last success, issued serial, ...).
+ *
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+/**
+ *  JobState is the persisted state of one job.
+ *
+ */
+type JobState struct {
+	LastAttempt   time.Time `json:"last_attempt"`
+	LastStatus    string    `json:"last_status"`
+	LastSuccess   time.Time `json:"last_success"`
+	LastRenewal   time.Time `json:"last_renewal"`
+	Serial        string    `json:"serial"` // upper case hex, like EJBCA
+	NotAfter      time.Time `json:"not_after"`
+	Fingerprint   string    `json:"fingerprint"`
+	LastError     string    `json:"last_error"`
+	Failures      int       `json:"consecutive_failures"`
+	TotalFailures int       `json:"total_failures"`
+}
+
+/**
+ *  Store holds the state of all jobs and persists it to a JSON file.
+ *  It is safe for concurrent use by parallel jobs.
+ *
+ */
+type Store struct {
+	mu   sync.Mutex
+	path string
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+/**
+ *  Open loads the store from the given file. A missing file results in an empty store.
+ *
+ *  Params:
+ *    - path: location of the JSON state file.
+ *
+ *  Returns:
+ *    - *Store: loaded store.
+ *    - error: non-nil if the file exists but cannot be read or parsed.
+ *
+ */
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, Jobs: map[string]*JobState{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read state %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse state %q: %w", path, err)
+	}
+	if s.Jobs == nil {
+		s.Jobs = map[string]*JobState{}
+	}
+	return s, nil
+}
+
+/**
+ *  Path returns the file the store is persisted to.
+ *
+ */
+func (s *Store) Path() string {
+	return s.path
+}
+
+/**
+ *  Update modifies the state of a job and writes the store to disk.
+ *
+ *  Params:
+ *    - name: job name.
+ *    - fn: function modifying the job state (a new state is created if none exists).
+ *
+ *  Returns:
+ *    - error: non-nil if the store cannot be written.
+ *
+ */
+func (s *Store) Update(name string, fn func(*JobState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	js, ok := s.Jobs[name]
+	if !ok {
+		js = &JobState{}
+		s.Jobs[name] = js
+	}
+	fn(js)
+
+	return s.save()
+}
+
+/**
+ *  Get returns a copy of the state of a job.
+ *
+ *  Params:
+ *    - name: job name.
+ *
+ *  Returns:
+ *    - JobState: state of the job.
+ *    - bool: false if nothing is recorded for the job.
+ *
+ */
+func (s *Store) Get(name string) (JobState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	js, ok := s.Jobs[name]
+	if !ok {
+		return JobState{}, false
+	}
+	return *js, true
+}
+
+/**
+ *  Names returns the sorted names of all jobs with recorded state.
+ *
+ */
+func (s *Store) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.Jobs))
+	for n := range s.Jobs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/**
+ *  save writes the store atomically (temporary file + rename). Caller holds s.mu.
+ *
+ */
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("write state %q: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state %q: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write state %q: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("write state %q: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file implements the "status" command which prints the
+ *  results of previous runs recorded in the state store.
+ * 
+ * */
+
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"github.com/tseiman/embed-cert-manager/state"
+)
+
+
+/**
+ *  runStatus prints the recorded state of all jobs, or only of the given jobs.
+ *  Params:
+ *    - names: job names to print, all jobs if empty.
+ * */
+func runStatus(names []string) {
+
+	store := openStateStore()
+	if store == nil { os.Exit(exitConfigError) }
+
+	if len(names) == 0 {
+		names = store.Names()
+	}
+	if len(names) == 0 {
+		fmt.Printf("no job state recorded in %s\n", store.Path())
+		return
+	}
+
+	printStatus(os.Stdout, store, names)
+}
+
+
+/**
+ *  printStatus writes the state of the given jobs as table.
+ *  Params:
+ *    - w: destination of the table.
+ *    - store: state store to read from.
+ *    - names: jobs to print.
+ * */
+func printStatus(w io.Writer, store *state.Store, names []string) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tLAST ATTEMPT\tSTATUS\tLAST SUCCESS\tLAST RENEWAL\tSERIAL\tNOT AFTER\tFAILURES\tFINGERPRINT\tLAST ERROR")
+	for _, name := range names {
+		js, ok := store.Get(name)
+		if !ok {
+			fmt.Fprintf(tw, "%s\t-\tunknown\t-\t-\t-\t-\t-\t-\t-\n", name)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			name,
+			fmtTime(js.LastAttempt),
+			orDash(js.LastStatus),
+			fmtTime(js.LastSuccess),
+			fmtTime(js.LastRenewal),
+			orDash(js.Serial),
+			fmtTime(js.NotAfter),
+			strconv.Itoa(js.Failures) + "/" + strconv.Itoa(js.TotalFailures),
+			orDash(js.Fingerprint),
+			orDash(js.LastError),
+		)
+	}
+	tw.Flush()
+}
+
+
+/**
+ *  fmtTime formats a time as RFC3339, or "-" if it is not set.
+ * */
+func fmtTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+
+/**
+ *  orDash returns s, or "-" if s is empty.
+ * */
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
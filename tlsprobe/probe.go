@@ -0,0 +1,102 @@
+package tlsprobe
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ *
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ *
+ *  Package tlsprobe performs TLS handshakes against services on target hosts
+ *  to find out which certificate they actually present.
+ *
+ */
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/tseiman/embed-cert-manager/logger"
+)
+
+const defaultTimeout = 10 * time.Second
+
+/**
+ *  Probe connects to a TLS service and returns the certificate chain presented by it.
+ *  The chain is not validated here, so also expired or self-signed certificates are returned.
+ *
+ *  Params:
+ *    - address: service address in host:port form.
+ *    - sni: server name sent in the handshake; if empty, the host part of address is used.
+ *
+ *  Returns:
+ *    - []*x509.Certificate: presented chain, leaf first.
+ *    - error: non-nil if the handshake fails or no certificate is presented.
+ *
+ */
+func Probe(address, sni string) ([]*x509.Certificate, error) {
+	if sni == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("probe address %q: %w", address, err)
+		}
+		sni = host
+	}
+
+	logger.Debugf("TLS probe %s (SNI %s)\n", address, sni)
+
+	dialer := &net.Dialer{Timeout: defaultTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName: sni,
+		// the presented chain is inspected by the caller
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	chain := conn.ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("TLS handshake with %s: no certificate presented", address)
+	}
+	return chain, nil
+}
+
+/**
+ *  VerifyChain validates a presented certificate chain against the CA certificates
+ *  given in PEM form. All certificates after the leaf are used as intermediates.
+ *
+ *  Params:
+ *    - chain: presented chain, leaf first.
+ *    - caPEM: trusted CA certificate(s) in PEM format.
+ *
+ *  Returns:
+ *    - error: non-nil if the chain does not verify against the CA certificates.
+ *
+ */
+func VerifyChain(chain []*x509.Certificate, caPEM string) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("empty certificate chain")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(caPEM)) {
+		return fmt.Errorf("no CA certificate found to verify against")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+
+/**
+ *  Copyright (c) 2026 Thomas Schmidt
+ *  SPDX-License-Identifier: MIT 
+ *  home: https://github.com/tseiman/embed-cert-manager/
+ * 
+ *  Tool to check and eventually renew a certificate on an embedded client
+ *  with limited software capabilities.
+ * 
+ *  This file implements the post-install verification: a TLS handshake
+ *  against the service on the target to check it picked up the new certificate.
+ * 
+ * */
+
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+	"github.com/tseiman/embed-cert-manager/config"
+	"github.com/tseiman/embed-cert-manager/ejbcaHttpsClient"
+	"github.com/tseiman/embed-cert-manager/tlsprobe"
+)
+
+
+/**
+ *  verifyInstall checks that the service configured in [verify] tls_address presents
+ *  the newly issued certificate and that its chain validates against ca_cert.
+ *  The probe is tried [verify] attempts times, each after waiting [verify] wait.
+ *  Params:
+ *    - job: job which was installed.
+ *    - issued: certificate just received from the CA.
+ *  Returns:
+ *    - error: nil if verification succeeded or is not configured.
+ * */
+func verifyInstall(job *config.Job, issued *x509.Certificate) error {
+
+	if job.Verify.TLSAddress == "" {
+		return nil
+	}
+
+	log := job.Log()
+	wait := time.Duration(job.Verify.Wait) * time.Second
+
+	var err error
+	for attempt := 1; attempt <= job.Verify.Attempts; attempt++ {
+		time.Sleep(wait)
+
+		err = probeInstalled(job, issued)
+		if err == nil {
+			log.Infof("verified %s presents the new certificate\n", job.Verify.TLSAddress)
+			return nil
+		}
+		log.Warnf("verify attempt %d/%d: %v\n", attempt, job.Verify.Attempts, err)
+	}
+	return fmt.Errorf("verify %s: %w", job.Verify.TLSAddress, err)
+}
+
+
+/**
+ *  probeInstalled performs one TLS handshake and checks the presented chain.
+ *  Params:
+ *    - job: job which was installed.
+ *    - issued: certificate just received from the CA.
+ *  Returns:
+ *    - error: nil if the presented leaf is the issued certificate and the chain validates.
+ * */
+func probeInstalled(job *config.Job, issued *x509.Certificate) error {
+
+	chain, err := tlsprobe.Probe(job.Verify.TLSAddress, job.Verify.SNI)
+	if err != nil {
+		return err
+	}
+
+	if err := ejbcaHttpsClient.CompareCertificates(chain[0], issued); err != nil {
+		return fmt.Errorf("service presents a different certificate: %w", err)
+	}
+
+	if job.Ca.CACertLoaded == "" {
+		job.Log().Warnln("no ca_cert loaded - presented chain is not validated")
+		return nil
+	}
+	if err := tlsprobe.VerifyChain(chain, job.Ca.CACertLoaded); err != nil {
+		return fmt.Errorf("chain validation against ca_cert: %w", err)
+	}
+	return nil
+}